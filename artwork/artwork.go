@@ -0,0 +1,409 @@
+// Package artwork downloads TMDB artwork into the local layout read by
+// Kodi, Jellyfin and Emby: poster.jpg, fanart.jpg and clearlogo.png next
+// to a movie or series, season01-poster.jpg for seasons, <episode>-thumb.jpg
+// for episodes and .actors/<Name>.jpg for the cast.
+package artwork
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+// manifestName records, in every directory written to, which TMDB image
+// each file was downloaded from, so that unchanged files are skipped
+// without a request.
+const manifestName = ".artwork.json"
+
+// Client is the part of tmdb.Client used to find artwork. persistent.Client
+// implements it as well, so the image lists can be served from the cache.
+type Client interface {
+	GetImage(path string, size string) string
+	GetMovieImages(id int, opts *tmdb.ImagesRequest) (*tmdb.Images, error)
+	GetTVDetail(id int, opts *tmdb.TVDetailRequest) (*tmdb.TVDetail, error)
+	GetTVImages(id int, opts *tmdb.ImagesRequest) (*tmdb.Images, error)
+	GetTVSeasonImages(id int, season int, opts *tmdb.ImagesRequest) (*tmdb.Images, error)
+	GetTVEpisodeImages(id int, season int, episode int, opts *tmdb.ImagesRequest) (*tmdb.Images, error)
+}
+
+// Downloader fetches artwork. Its methods may be called concurrently.
+type Downloader struct {
+	Client Client
+	// HTTPClient downloads the images, http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// CacheDir keeps the bytes of every image downloaded, so that
+	// artwork written again, elsewhere or after a change of layout, is
+	// read from disk. Nothing is cached when empty.
+	CacheDir string
+	// Language is preferred for posters and logos, such as "en". Images
+	// without text come next, then the other languages.
+	Language string
+	// Size is the TMDB image size, such as "w780". Zero means original.
+	Size string
+	// Concurrency bounds the downloads in flight, 4 when zero.
+	Concurrency int
+
+	once      sync.Once
+	sem       chan struct{}
+	mu        sync.Mutex
+	manifests map[string]map[string]string
+}
+
+// Status is what happened to a file.
+type Status string
+
+const (
+	Downloaded Status = "downloaded"
+	// Cached files were written from CacheDir without a download.
+	Cached Status = "cached"
+	// Unchanged files already held the selected image.
+	Unchanged Status = "unchanged"
+	Failed    Status = "failed"
+)
+
+// Result reports one file.
+type Result struct {
+	File   string `json:"file"`
+	Image  string `json:"image"`
+	Status Status `json:"status"`
+	Err    error  `json:"-"`
+}
+
+// job is one file to write from one TMDB image path.
+type job struct {
+	file  string
+	image string
+}
+
+// Movie writes the poster, fanart and logo of a movie into dir.
+func (d *Downloader) Movie(ctx context.Context, id int, dir string) ([]Result, error) {
+	images, err := d.Client.GetMovieImages(id, d.request())
+	if err != nil {
+		return nil, err
+	}
+	return d.run(ctx, d.main(images, dir))
+}
+
+// TV writes the poster, fanart and logo of a series into dir, and the
+// poster of every season as season01-poster.jpg and so on, with
+// season-specials-poster.jpg for season 0.
+func (d *Downloader) TV(ctx context.Context, id int, dir string) ([]Result, error) {
+	images, err := d.Client.GetTVImages(id, d.request())
+	if err != nil {
+		return nil, err
+	}
+	jobs := d.main(images, dir)
+	detail, err := d.Client.GetTVDetail(id, &tmdb.TVDetailRequest{})
+	if err != nil {
+		return nil, err
+	}
+	for _, season := range detail.Seasons {
+		images, err := d.Client.GetTVSeasonImages(id, season.SeasonNumber, d.request())
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("season%02d-poster", season.SeasonNumber)
+		if season.SeasonNumber == 0 {
+			name = "season-specials-poster"
+		}
+		if image := Best(images.Posters, d.Language); image != nil {
+			jobs = append(jobs, job{filepath.Join(dir, name+ext(image.FilePath)), image.FilePath})
+		}
+	}
+	return d.run(ctx, jobs)
+}
+
+// Episode writes the still of an episode next to its video file, as
+// "<video name>-thumb.jpg".
+func (d *Downloader) Episode(ctx context.Context, id, season, episode int, video string) ([]Result, error) {
+	images, err := d.Client.GetTVEpisodeImages(id, season, episode, d.request())
+	if err != nil {
+		return nil, err
+	}
+	image := Best(images.Stills, "")
+	if image == nil {
+		return nil, nil
+	}
+	base := strings.TrimSuffix(video, filepath.Ext(video))
+	return d.run(ctx, []job{{base + "-thumb" + ext(image.FilePath), image.FilePath}})
+}
+
+// Actors writes the profile picture of each member of the cast into the
+// .actors directory of dir, named after them with underscores for spaces.
+// When several people share a name, the first keeps it and the others
+// have their TMDB person ID appended, as in Name_123.jpg.
+func (d *Downloader) Actors(ctx context.Context, cast []tmdb.CastMember, dir string) ([]Result, error) {
+	type person struct {
+		name string
+		id   int
+	}
+	var jobs []job
+	seen := make(map[person]bool)
+	taken := make(map[string]bool)
+	for _, member := range cast {
+		name := strings.ReplaceAll(safeName(member.Name), " ", "_")
+		// The same person may play several roles.
+		if member.ProfilePath == "" || seen[person{name, member.ID}] {
+			continue
+		}
+		seen[person{name, member.ID}] = true
+		if taken[name] {
+			name = fmt.Sprintf("%s_%d", name, member.ID)
+		}
+		taken[name] = true
+		jobs = append(jobs, job{filepath.Join(dir, ".actors", name+ext(member.ProfilePath)), member.ProfilePath})
+	}
+	return d.run(ctx, jobs)
+}
+
+func (d *Downloader) request() *tmdb.ImagesRequest {
+	// The images endpoints only list the languages asked for.
+	include := "null"
+	if d.Language != "" {
+		include = d.Language + ",null"
+	}
+	return &tmdb.ImagesRequest{IncludeImageLanguage: include}
+}
+
+// main selects the poster, fanart and logo of a movie or series.
+func (d *Downloader) main(images *tmdb.Images, dir string) (jobs []job) {
+	for _, kind := range []struct {
+		name   string
+		images []tmdb.Image
+		// textless prefers images without text, as fanart is shown
+		// behind menus.
+		textless bool
+	}{
+		{"poster", images.Posters, false},
+		{"fanart", images.Backdrops, true},
+		{"clearlogo", images.Logos, false},
+	} {
+		language := d.Language
+		if kind.textless {
+			language = ""
+		}
+		if image := Best(kind.images, language); image != nil {
+			jobs = append(jobs, job{filepath.Join(dir, kind.name+ext(image.FilePath)), image.FilePath})
+		}
+	}
+	return
+}
+
+// Best returns the image to use among images: those in language first,
+// then those without text, then the rest, each by vote average, vote
+// count and width. It returns nil when images is empty.
+func Best(images []tmdb.Image, language string) *tmdb.Image {
+	if len(images) == 0 {
+		return nil
+	}
+	rank := func(image *tmdb.Image) int {
+		switch {
+		case language != "" && image.ISO639_1 == language:
+			return 0
+		case image.ISO639_1 == "" || image.ISO639_1 == "null":
+			return 1
+		}
+		return 2
+	}
+	sorted := make([]*tmdb.Image, len(images))
+	for i := range images {
+		sorted[i] = &images[i]
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		if a.VoteAverage != b.VoteAverage {
+			return a.VoteAverage > b.VoteAverage
+		}
+		if a.VoteCount != b.VoteCount {
+			return a.VoteCount > b.VoteCount
+		}
+		return a.Width > b.Width
+	})
+	return sorted[0]
+}
+
+// run writes the files of jobs with bounded concurrency. Failed files do
+// not stop the others and are joined in the returned error.
+func (d *Downloader) run(ctx context.Context, jobs []job) ([]Result, error) {
+	d.once.Do(func() {
+		concurrency := d.Concurrency
+		if concurrency < 1 {
+			concurrency = 4
+		}
+		d.sem = make(chan struct{}, concurrency)
+		d.manifests = make(map[string]map[string]string)
+	})
+	results := make([]Result, len(jobs))
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		i, j := i, j
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = Result{File: j.file, Image: j.image}
+			select {
+			case d.sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i].Status, results[i].Err = Failed, ctx.Err()
+				return
+			}
+			defer func() { <-d.sem }()
+			results[i].Status, results[i].Err = d.write(ctx, j)
+			if results[i].Err != nil {
+				results[i].Status = Failed
+			}
+		}()
+	}
+	wg.Wait()
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.File, r.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+func (d *Downloader) size() string {
+	if d.Size == "" {
+		return "original"
+	}
+	return d.Size
+}
+
+func (d *Downloader) write(ctx context.Context, j job) (Status, error) {
+	dir, name := filepath.Split(j.file)
+	source := d.size() + j.image
+	if d.source(dir, name) == source {
+		if _, err := os.Stat(j.file); err == nil {
+			return Unchanged, nil
+		}
+	}
+	status := Cached
+	cached := ""
+	if d.CacheDir != "" {
+		cached = filepath.Join(d.CacheDir, d.size(), path.Base(j.image))
+	}
+	data, err := os.ReadFile(cached)
+	if cached == "" || err != nil {
+		status = Downloaded
+		if data, err = d.download(ctx, j.image); err != nil {
+			return Failed, err
+		}
+		if cached != "" {
+			if err := writeFile(cached, data); err != nil {
+				return Failed, err
+			}
+		}
+	}
+	if err := writeFile(j.file, data); err != nil {
+		return Failed, err
+	}
+	return status, d.record(dir, name, source)
+}
+
+func (d *Downloader) download(ctx context.Context, image string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.Client.GetImage(image, d.size()), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := d.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("artwork: %s: %s", image, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// source returns the image the manifest of dir records for name.
+func (d *Downloader) source(dir, name string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.manifest(dir)[name]
+}
+
+// manifest returns the manifest of dir, reading it on first use. d.mu
+// must be held.
+func (d *Downloader) manifest(dir string) map[string]string {
+	m, ok := d.manifests[dir]
+	if !ok {
+		m = make(map[string]string)
+		if data, err := os.ReadFile(filepath.Join(dir, manifestName)); err == nil {
+			json.Unmarshal(data, &m)
+		}
+		d.manifests[dir] = m
+	}
+	return m
+}
+
+func (d *Downloader) record(dir, name, source string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m := d.manifest(dir)
+	m[name] = source
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, manifestName), data)
+}
+
+// writeFile writes atomically, so an interrupted download never leaves a
+// truncated image behind.
+func writeFile(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func ext(image string) string {
+	if e := path.Ext(image); e != "" {
+		return e
+	}
+	return ".jpg"
+}
+
+var unsafe = strings.NewReplacer("/", "", "\\", "", ":", "", "*", "", "?", "", "\"", "", "<", "", ">", "", "|", "")
+
+func safeName(name string) string {
+	return unsafe.Replace(name)
+}
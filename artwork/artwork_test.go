@@ -0,0 +1,247 @@
+package artwork
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/song940/tmdb-go/tmdb"
+	"github.com/song940/tmdb-go/tmdbtest"
+)
+
+func TestActorsConcurrently(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer server.Close()
+	client, err := tmdb.NewClient(&tmdb.Config{APIKey: "key", ImageURL: server.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cast []tmdb.CastMember
+	for i := 0; i < 200; i++ {
+		var member tmdb.CastMember
+		member.Name = fmt.Sprintf("Actor %d", i)
+		member.ProfilePath = fmt.Sprintf("/%d.jpg", i)
+		cast = append(cast, member)
+	}
+	d := &Downloader{Client: client, Concurrency: 16}
+	dir := t.TempDir()
+	for _, want := range []Status{Downloaded, Unchanged} {
+		results, err := d.Actors(context.Background(), cast, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(cast) {
+			t.Fatalf("got %d results, want %d", len(results), len(cast))
+		}
+		for _, r := range results {
+			if r.Status != want {
+				t.Fatalf("%s: got %s, want %s", r.File, r.Status, want)
+			}
+		}
+	}
+}
+
+// imageServer answers every image request with its path, and counts them.
+type imageServer struct {
+	downloads atomic.Int64
+}
+
+func (s *imageServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.downloads.Add(1)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(req.URL.Path)),
+		Request:    req,
+	}, nil
+}
+
+// newDownloader returns a Downloader of the tmdbtest fixtures.
+func newDownloader(t *testing.T) (*Downloader, *tmdbtest.Server, *imageServer) {
+	t.Helper()
+	server := tmdbtest.NewServer()
+	t.Cleanup(server.Close)
+	images := &imageServer{}
+	return &Downloader{Client: server.Client(), HTTPClient: &http.Client{Transport: images}, Language: "en"}, server, images
+}
+
+// checkFiles checks that dir holds exactly the files of want, each
+// downloaded from the image it maps to.
+func checkFiles(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == manifestName {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[entry.Name()] = path.Base(string(data))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s holds %v, want %v", dir, got, want)
+	}
+}
+
+func TestBest(t *testing.T) {
+	images := []tmdb.Image{
+		{FilePath: "/fr.jpg", ISO639_1: "fr", VoteAverage: 9, VoteCount: 100},
+		{FilePath: "/en-low.jpg", ISO639_1: "en", VoteAverage: 5, VoteCount: 100},
+		{FilePath: "/en-few.jpg", ISO639_1: "en", VoteAverage: 6, VoteCount: 2, Width: 1000},
+		{FilePath: "/en-many.jpg", ISO639_1: "en", VoteAverage: 6, VoteCount: 20, Width: 500},
+		{FilePath: "/textless.jpg", VoteAverage: 7},
+		{FilePath: "/null.jpg", ISO639_1: "null", VoteAverage: 8},
+	}
+	for _, test := range []struct {
+		images   []tmdb.Image
+		language string
+		want     string
+	}{
+		{images, "en", "/en-many.jpg"},
+		{images, "fr", "/fr.jpg"},
+		{images, "de", "/null.jpg"},
+		{images, "", "/null.jpg"},
+		{images[:4], "de", "/fr.jpg"},
+		{[]tmdb.Image{{FilePath: "/narrow.jpg", Width: 500}, {FilePath: "/wide.jpg", Width: 2000}}, "", "/wide.jpg"},
+		{[]tmdb.Image{{FilePath: "/first.jpg"}, {FilePath: "/second.jpg"}}, "", "/first.jpg"},
+	} {
+		if got := Best(test.images, test.language); got == nil || got.FilePath != test.want {
+			t.Errorf("Best(%d images, %q) = %v, want %s", len(test.images), test.language, got, test.want)
+		}
+	}
+	if got := Best(nil, "en"); got != nil {
+		t.Errorf("Best(nil) = %v, want nil", got)
+	}
+}
+
+func TestMovieLayout(t *testing.T) {
+	d, _, _ := newDownloader(t)
+	dir := t.TempDir()
+	if _, err := d.Movie(context.Background(), tmdbtest.MovieID, dir); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"poster.jpg":    "f89U3ADr1oiB1s9GkdPOEpXUk5H.jpg",
+		"fanart.jpg":    "fNG7i7RqMErkcqhohV2a6cV1Ehy.jpg",
+		"clearlogo.png": "ivBd1twX3zTAsyzvyLJgKrSCZ4a.png",
+	})
+
+	// Without a poster in the language, the textless one is preferred.
+	d.Language = "fr"
+	results, err := d.Movie(context.Background(), tmdbtest.MovieID, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		want := Unchanged
+		if filepath.Base(r.File) == "poster.jpg" {
+			want = Downloaded
+		}
+		if r.Status != want {
+			t.Errorf("%s: got %s, want %s", r.File, r.Status, want)
+		}
+	}
+	checkFiles(t, dir, map[string]string{
+		"poster.jpg":    "aOIuZAjPaRIE6CMzbazvcHuHXDc.jpg",
+		"fanart.jpg":    "fNG7i7RqMErkcqhohV2a6cV1Ehy.jpg",
+		"clearlogo.png": "ivBd1twX3zTAsyzvyLJgKrSCZ4a.png",
+	})
+}
+
+func TestTVLayout(t *testing.T) {
+	d, server, _ := newDownloader(t)
+	server.HandleJSON("/tv/1399/season/0/images", tmdb.Images{Posters: []tmdb.Image{{FilePath: "/specials.jpg"}}})
+	server.HandleJSON("/tv/1399/season/2/images", tmdb.Images{})
+	dir := t.TempDir()
+	if _, err := d.TV(context.Background(), tmdbtest.TVID, dir); err != nil {
+		t.Fatal(err)
+	}
+	video := filepath.Join(dir, "Season 01", "Game of Thrones - S01E01.mkv")
+	if _, err := d.Episode(context.Background(), tmdbtest.TVID, tmdbtest.SeasonNumber, tmdbtest.EpisodeNumber, video); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"poster.jpg":                 "1XS1oqL89opfnbLl8WnZY1O1uJx.jpg",
+		"fanart.jpg":                 "2OMB0ynKlyIenMJWI2Dy9IWT4c.jpg",
+		"clearlogo.png":              "jzVRy7NZx7bD6EDkd9mnzjy6ko1.png",
+		"season-specials-poster.jpg": "specials.jpg",
+		"season01-poster.jpg":        "wgfKiqzuMrFIkU1M68DDDY8kGC1.jpg",
+	})
+	checkFiles(t, filepath.Dir(video), map[string]string{
+		"Game of Thrones - S01E01-thumb.jpg": "9hGF3WUkBf7cSjMg0cdMDHJkByd.jpg",
+	})
+}
+
+func TestCacheDir(t *testing.T) {
+	d, _, images := newDownloader(t)
+	d.CacheDir = t.TempDir()
+	d.Size = "w780"
+	for i, want := range []Status{Downloaded, Cached} {
+		dir := t.TempDir()
+		results, err := d.Movie(context.Background(), tmdbtest.MovieID, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.Status != want {
+				t.Errorf("run %d: %s: got %s, want %s", i, r.File, r.Status, want)
+			}
+		}
+		checkFiles(t, dir, map[string]string{
+			"poster.jpg":    "f89U3ADr1oiB1s9GkdPOEpXUk5H.jpg",
+			"fanart.jpg":    "fNG7i7RqMErkcqhohV2a6cV1Ehy.jpg",
+			"clearlogo.png": "ivBd1twX3zTAsyzvyLJgKrSCZ4a.png",
+		})
+	}
+	if got := images.downloads.Load(); got != 3 {
+		t.Errorf("downloaded %d images, want 3", got)
+	}
+	if _, err := os.Stat(filepath.Join(d.CacheDir, "w780", "f89U3ADr1oiB1s9GkdPOEpXUk5H.jpg")); err != nil {
+		t.Errorf("poster not cached by size: %v", err)
+	}
+}
+
+func TestActorsSharingAName(t *testing.T) {
+	d, _, _ := newDownloader(t)
+	member := func(id int, name, profile string) (m tmdb.CastMember) {
+		m.ID, m.Name, m.ProfilePath = id, name, profile
+		return
+	}
+	cast := []tmdb.CastMember{
+		member(1, "John Smith", "/a.jpg"),
+		member(2, "John Smith", "/b.jpg"),
+		// The same person in a second role.
+		member(1, "John Smith", "/a.jpg"),
+		member(3, "Jane Doe", "/c.jpg"),
+		member(4, "No Picture", ""),
+	}
+	dir := t.TempDir()
+	results, err := d.Actors(context.Background(), cast, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Errorf("got %d results, want 3", len(results))
+	}
+	checkFiles(t, filepath.Join(dir, ".actors"), map[string]string{
+		"John_Smith.jpg":   "a.jpg",
+		"John_Smith_2.jpg": "b.jpg",
+		"Jane_Doe.jpg":     "c.jpg",
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/song940/tmdb-go/persistent"
+)
+
+func runCache(client *persistent.Client, opts *options, args []string) error {
+	fs := newFlagSet(opts, "cache", "stats | list [resource] | prune [flags] | purge")
+	olderThan := fs.Duration("older-than", 0, "prune: remove entries fetched longer ago than this, such as 720h")
+	prefix := fs.String("prefix", "", "prune: remove entries whose key starts with this")
+	notFound := fs.Bool("not-found", false, "prune: remove cached not-found responses")
+	maxSize := fs.Int64("max-size", 0, "prune: evict least recently used entries down to this many `bytes`")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return usagef("expected stats, list, prune or purge")
+	}
+	switch args[0] {
+	case "stats":
+		stats, err := client.Stats()
+		if err != nil {
+			return err
+		}
+		var records []resourceRecord
+		for resource, r := range stats.Resources {
+			records = append(records, resourceRecord{resource, r.Entries, r.Size})
+		}
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].Resource < records[j].Resource
+		})
+		records = append(records,
+			resourceRecord{Resource: "not found", Entries: stats.NotFound},
+			resourceRecord{"total", stats.Entries, stats.Size},
+		)
+		return opts.print(&result{
+			value:   stats,
+			records: records,
+			columns: []string{"resource", "entries", "size"},
+		})
+	case "list":
+		resource := ""
+		if len(args) > 1 {
+			resource = args[1]
+		}
+		entries, err := client.Entries(resource)
+		if err != nil {
+			return err
+		}
+		return opts.print(&result{
+			value:   entries,
+			records: entries,
+			columns: []string{"key", "request", "size", "mod_time"},
+		})
+	case "prune":
+		var prunes []func() (int, error)
+		if *olderThan > 0 {
+			prunes = append(prunes, func() (int, error) { return client.PruneOlderThan(*olderThan) })
+		}
+		if *prefix != "" {
+			prunes = append(prunes, func() (int, error) { return client.PrunePrefix(*prefix) })
+		}
+		if *notFound {
+			prunes = append(prunes, client.PurgeNotFound)
+		}
+		if *maxSize > 0 {
+			prunes = append(prunes, func() (int, error) { return client.Evict(*maxSize) })
+		}
+		if len(prunes) == 0 {
+			return usagef("prune needs -older-than, -prefix, -not-found or -max-size")
+		}
+		total := 0
+		for _, prune := range prunes {
+			n, err := prune()
+			total += n
+			if err != nil {
+				return err
+			}
+		}
+		return opts.printRemoved(total)
+	case "purge":
+		n, err := client.Purge()
+		if err != nil {
+			return err
+		}
+		return opts.printRemoved(n)
+	}
+	return usagef("unknown cache subcommand %q, expected stats, list, prune or purge", args[0])
+}
+
+type resourceRecord struct {
+	Resource string `json:"resource"`
+	Entries  int    `json:"entries"`
+	Size     int64  `json:"size"`
+}
+
+func (opts *options) printRemoved(n int) error {
+	if opts.output == formatTable && opts.template == "" {
+		fmt.Fprintf(stdout, "removed %d entries\n", n)
+		return nil
+	}
+	return opts.print(&result{value: struct {
+		Removed int `json:"removed"`
+	}{n}})
+}
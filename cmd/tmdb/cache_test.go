@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/song940/tmdb-go/persistent"
+	"github.com/song940/tmdb-go/tmdb"
+	"github.com/song940/tmdb-go/tmdbtest"
+)
+
+// newCachedClient returns a client whose cache holds a movie, a series and
+// a not-found response.
+func newCachedClient(t *testing.T) *persistent.Client {
+	t.Helper()
+	client, _ := newTestClient(t)
+	if _, err := client.GetMovieDetail(tmdbtest.MovieID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTVDetail(tmdbtest.TVID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetMovieDetail(1, nil); !errors.Is(err, tmdb.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	return client
+}
+
+func TestCacheStats(t *testing.T) {
+	client := newCachedClient(t)
+	out := captureStdout(t)
+	if err := runCache(client, &options{output: formatTable}, []string{"stats", "-output", "json"}); err != nil {
+		t.Fatal(err)
+	}
+	var stats persistent.Stats
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 3 || stats.NotFound != 1 || stats.Misses != 3 || stats.Resources["movie"] == nil || stats.Resources["tv"] == nil {
+		t.Errorf("got stats %+v", stats)
+	}
+
+	out.Reset()
+	if err := runCache(client, &options{output: formatTable}, []string{"stats"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || strings.Join(strings.Fields(lines[0]), " ") != "RESOURCE ENTRIES SIZE" {
+		t.Fatalf("got table\n%s", out)
+	}
+	for i, want := range []string{"movie 1", "tv 1", "not found 1", "total 3"} {
+		if !strings.HasPrefix(strings.Join(strings.Fields(lines[i+1]), " "), want) {
+			t.Errorf("line %d is %q, want it to start with %q", i+1, lines[i+1], want)
+		}
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	client := newCachedClient(t)
+	out := captureStdout(t)
+	for _, step := range []struct {
+		args []string
+		want string
+		left int
+	}{
+		{[]string{"prune", "-not-found"}, "removed 1 entries\n", 2},
+		{[]string{"prune", "-prefix", "tv-"}, "removed 1 entries\n", 1},
+		{[]string{"prune", "-older-than", "1h"}, "removed 0 entries\n", 1},
+		{[]string{"purge", "-output", "json"}, "{\n  \"removed\": 1\n}\n", 0},
+	} {
+		out.Reset()
+		if err := runCache(client, &options{output: formatTable}, step.args); err != nil {
+			t.Fatalf("%q: %v", step.args, err)
+		}
+		if out.String() != step.want {
+			t.Errorf("%q printed %q, want %q", step.args, out, step.want)
+		}
+		list, err := client.Store.List("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != step.left {
+			t.Errorf("%q left %d entries, want %d", step.args, len(list), step.left)
+		}
+	}
+}
+
+func TestCacheUsage(t *testing.T) {
+	client := newCachedClient(t)
+	captureStdout(t)
+	for _, args := range [][]string{
+		nil,
+		{"prune"},
+		// Flags after "--" are positional.
+		{"prune", "--", "-not-found"},
+		{"shrink"},
+		{"stats", "-output", "xml"},
+	} {
+		var usageErr *usageError
+		if err := runCache(client, &options{output: formatTable}, args); !errors.As(err, &usageErr) {
+			t.Errorf("%q: got %v, want a usage error", args, err)
+		}
+	}
+	list, err := client.Store.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Errorf("usage errors left %d entries, want 3", len(list))
+	}
+}
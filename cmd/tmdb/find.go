@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/song940/tmdb-go/persistent"
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+var findSources = map[string]string{
+	"imdb":     tmdb.SourceIMDb,
+	"tvdb":     tmdb.SourceTVDB,
+	"wikidata": tmdb.SourceWikidata,
+}
+
+// findRecord is one match of any type. ID is the series ID for seasons
+// and episodes.
+type findRecord struct {
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Date string `json:"date,omitempty"`
+}
+
+func runFind(client *persistent.Client, opts *options, args []string) error {
+	fs := newFlagSet(opts, "find", "imdb|tvdb|wikidata <id>")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usagef("expected a source and an external ID, such as: find imdb tt0133093")
+	}
+	source, ok := findSources[args[0]]
+	if !ok {
+		return usagef("unknown source %q, expected imdb, tvdb or wikidata", args[0])
+	}
+	res, err := client.Find(args[1], &tmdb.FindRequest{ExternalSource: source, Language: opts.language})
+	if err != nil {
+		return err
+	}
+	var records []findRecord
+	for _, movie := range res.MovieResults {
+		records = append(records, findRecord{"movie", movie.ID, movie.Title, movie.ReleaseDate})
+	}
+	for _, tv := range res.TVResults {
+		records = append(records, findRecord{"tv", tv.ID, tv.Name, tv.FirstAirDate})
+	}
+	for _, season := range res.TVSeasonResults {
+		records = append(records, findRecord{"season", season.ShowID, season.Name, season.AirDate})
+	}
+	for _, episode := range res.TVEpisodeResults {
+		records = append(records, findRecord{"episode", episode.ShowID, episode.Name, episode.AirDate})
+	}
+	for _, person := range res.PersonResults {
+		records = append(records, findRecord{Type: "person", ID: person.ID, Name: person.Name})
+	}
+	if len(records) == 0 {
+		return &tmdb.Error{
+			TMDBResponse: tmdb.TMDBResponse{StatusCode: tmdb.StatusNotFound, StatusMessage: "no match for " + args[1]},
+		}
+	}
+	return opts.print(&result{
+		value:   res,
+		records: records,
+		columns: []string{"type", "id", "name", "date"},
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// newFlagSet returns a flag set for a subcommand that returns errors
+// instead of printing them and exiting, with the output flags.
+func newFlagSet(opts *options, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet("tmdb "+name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	outputFlags(fs, opts)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tmdb %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func outputFlags(fs *flag.FlagSet, opts *options) {
+	fs.Var((*outputFormat)(&opts.output), "output", "output `format`: table, json, ndjson, csv or yaml")
+	fs.StringVar(&opts.template, "template", opts.template, "print with a Go text/template, once per record")
+	fs.StringVar(&opts.columns, "columns", opts.columns, "comma separated `fields` for table and csv output, or all")
+}
+
+// outputFormat is a flag.Value that rejects unknown formats while the
+// flags are parsed, before any request is made.
+type outputFormat string
+
+func (f *outputFormat) String() string { return string(*f) }
+
+func (f *outputFormat) Set(s string) error {
+	switch s {
+	case formatTable, formatJSON, formatNDJSON, formatCSV, formatYAML:
+		*f = outputFormat(s)
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, expected table, json, ndjson, csv or yaml", s)
+}
+
+// parse parses flags placed anywhere among the positional arguments, so
+// that both "search movie -year 1999 matrix" and "search movie matrix
+// -year 1999" work, and returns the positional arguments. Everything after
+// a "--" is positional, such as a file named "-x.mkv".
+func parse(fs *flag.FlagSet, args []string) (positional []string, err error) {
+	for {
+		if err = fs.Parse(args); err == flag.ErrHelp {
+			fs.SetOutput(os.Stdout)
+			fs.Usage()
+			return
+		}
+		if err != nil {
+			return nil, usagef("%s", err)
+		}
+		rest := fs.Args()
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 0 {
+		return 0, usagef("invalid ID %q", s)
+	}
+	return id, nil
+}
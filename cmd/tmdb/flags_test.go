@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		args       []string
+		positional []string
+		year       int
+		adult      bool
+	}{
+		{[]string{"matrix"}, []string{"matrix"}, 0, false},
+		{[]string{"-year", "1999", "matrix"}, []string{"matrix"}, 1999, false},
+		{[]string{"matrix", "-year", "1999"}, []string{"matrix"}, 1999, false},
+		{[]string{"the", "-year=1999", "matrix", "-adult"}, []string{"the", "matrix"}, 1999, true},
+		{[]string{"--", "-x.mkv", "-year", "2000"}, []string{"-x.mkv", "-year", "2000"}, 0, false},
+		{[]string{"a", "-adult", "--", "-b", "--"}, []string{"a", "-b", "--"}, 0, true},
+		{[]string{"-year", "1999", "--"}, nil, 1999, false},
+	} {
+		opts := &options{}
+		fs := newFlagSet(opts, "test", "")
+		year := fs.Int("year", 0, "")
+		adult := fs.Bool("adult", false, "")
+		positional, err := parse(fs, test.args)
+		if err != nil {
+			t.Errorf("parse(%q): %v", test.args, err)
+			continue
+		}
+		if fmt.Sprintf("%q", positional) != fmt.Sprintf("%q", test.positional) || *year != test.year || *adult != test.adult {
+			t.Errorf("parse(%q) = %q, year %d, adult %v, want %q, %d, %v", test.args, positional, *year, *adult, test.positional, test.year, test.adult)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-bogus"},
+		{"matrix", "-year", "nineteen"},
+		{"matrix", "-year"},
+		{"-output", "xml"},
+	} {
+		opts := &options{}
+		fs := newFlagSet(opts, "test", "")
+		fs.Int("year", 0, "")
+		_, err := parse(fs, args)
+		var usageErr *usageError
+		if !errors.As(err, &usageErr) {
+			t.Errorf("parse(%q): got %v, want a usage error", args, err)
+		}
+	}
+	fs := newFlagSet(&options{}, "test", "")
+	if _, err := parse(fs, []string{"-output", "csv", "-columns", "id,title"}); err != nil {
+		t.Errorf("output flags: %v", err)
+	}
+	if _, err := parse(flag.NewFlagSet("test", flag.ContinueOnError), nil); err != nil {
+		t.Errorf("no arguments: %v", err)
+	}
+}
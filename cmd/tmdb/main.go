@@ -0,0 +1,183 @@
+// Command tmdb queries The Movie Database from the command line, caching
+// responses on disk.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/song940/tmdb-go/persistent"
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+const usage = `Usage: tmdb [flags] <command> [arguments]
+
+Commands:
+  search movie|tv|person <query>      search by title or name
+  movie <id> [credits|images|videos]  show a movie
+  tv <id> [credits]                   show a TV series
+  tv <id> season <n> [episode <m>]    show a season or an episode
+  find imdb|tvdb <id>                 look up an external ID
+  cache stats|list|prune|purge        inspect and maintain the cache
+  rename <file or directory>...       rename media files after TMDB
+  serve                               serve the API from the cache to other
+                                      clients, with the configured key
+
+Run "tmdb <command> -h" for the flags of a command. The output flags
+may be given before or after the command, for example:
+
+  tmdb search movie -output csv -columns id,title matrix
+  tmdb tv 1399 season 1 -template '{{.Episode}}. {{.Name}}'
+
+Flags:
+`
+
+const environment = `
+Environment:
+  TMDB_API_KEY        API key (v3 auth)
+  TMDB_ACCESS_TOKEN   API read access token (v4 auth)
+  TMDB_CONFIG         config file, by default config.yaml or config.toml
+                      in the user config directory (tmdb/)
+  TMDB_PROFILE        config profile
+  TMDB_API, TMDB_IMAGE_URL, TMDB_CACHE_DIR, TMDB_CACHE_POLICY,
+  TMDB_CACHE_MAX_AGE, TMDB_CACHE_MAX_SIZE, TMDB_CACHE_COMPRESSION
+                      override the config file, and are overridden by flags
+`
+
+// Exit codes.
+const (
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+// usageError reports a malformed command line.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// options are the global flags. The output flags are also accepted after
+// the command.
+type options struct {
+	config   string
+	profile  string
+	language string
+	cache    string
+	offline  bool
+	refresh  bool
+
+	output   string
+	template string
+	columns  string
+}
+
+type command func(client *persistent.Client, opts *options, args []string) error
+
+var commands = map[string]command{
+	"search": runSearch,
+	"movie":  runMovie,
+	"tv":     runTV,
+	"find":   runFind,
+	"cache":  runCache,
+	"rename": runRename,
+	"serve":  runServe,
+}
+
+func main() {
+	opts := &options{output: formatTable}
+	flag.StringVar(&opts.config, "config", "", "config `file`, YAML or TOML")
+	flag.StringVar(&opts.profile, "profile", "", "config `profile` to use")
+	flag.StringVar(&opts.language, "language", "", "language of the results, such as en-US or fr-FR")
+	flag.StringVar(&opts.cache, "cache", "", "cache `directory` (default: the user config directory)")
+	flag.BoolVar(&opts.offline, "offline", false, "serve from the cache only, never use the network")
+	flag.BoolVar(&opts.refresh, "refresh", false, "always fetch, then refresh the cache")
+	outputFlags(flag.CommandLine, opts)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), environment)
+	}
+	flag.Parse()
+	os.Exit(run(opts, flag.Args()))
+}
+
+func run(opts *options, args []string) int {
+	if len(args) == 0 {
+		flag.Usage()
+		return exitUsage
+	}
+	if args[0] == "help" {
+		flag.CommandLine.SetOutput(os.Stdout)
+		flag.Usage()
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "tmdb: unknown command %q\n", args[0])
+		fmt.Fprintln(os.Stderr, `Run "tmdb help" for usage.`)
+		return exitUsage
+	}
+	client, err := newClient(opts)
+	if err == nil {
+		err = cmd(client, opts, args[1:])
+	}
+	// Transport errors carry the request URL, which includes the API key.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var usageErr *usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(os.Stderr, "tmdb %s: %s\n", args[0], err)
+		fmt.Fprintln(os.Stderr, `Run "tmdb help" for usage.`)
+		return exitUsage
+	case errors.Is(err, tmdb.ErrNotFound):
+		fmt.Fprintf(os.Stderr, "tmdb %s: not found: %s\n", args[0], err)
+		return exitNotFound
+	default:
+		fmt.Fprintf(os.Stderr, "tmdb %s: %s\n", args[0], err)
+		return exitError
+	}
+}
+
+func newClient(opts *options) (*persistent.Client, error) {
+	config, err := persistent.LoadConfig(&persistent.LoadOptions{
+		Path:    opts.config,
+		Profile: opts.profile,
+	})
+	if err != nil {
+		// The configuration is user input like the flags.
+		return nil, usagef("%s", err)
+	}
+	if opts.cache != "" {
+		config.PersistentPath = opts.cache
+	}
+	switch {
+	case opts.offline && opts.refresh:
+		return nil, usagef("-offline and -refresh are mutually exclusive")
+	case opts.offline:
+		config.Policy = persistent.CacheOnly
+	case opts.refresh:
+		config.Policy = persistent.NetworkOnly
+	}
+	config.OnStoreError = func(key string, err error) {
+		fmt.Fprintf(os.Stderr, "tmdb: not cached: %s: %s\n", key, err)
+	}
+	return persistent.NewClient(config)
+}
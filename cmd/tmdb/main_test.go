@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/song940/tmdb-go/persistent"
+	"github.com/song940/tmdb-go/tmdbtest"
+)
+
+// newTestClient returns a client of a tmdbtest server, caching in memory.
+func newTestClient(t *testing.T) (*persistent.Client, *tmdbtest.Server) {
+	t.Helper()
+	server := tmdbtest.NewServer()
+	t.Cleanup(server.Close)
+	client, err := persistent.NewClient(&persistent.Config{
+		Config: *server.Config(),
+		Store:  persistent.NewMemoryStore(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+// captureStdout collects what commands print for the rest of the test.
+func captureStdout(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := stdout
+	stdout = &buf
+	t.Cleanup(func() { stdout = saved })
+	return &buf
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/song940/tmdb-go/persistent"
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+// movieDetail adds full image URLs to tmdb.MovieDetail.
+type movieDetail struct {
+	*tmdb.MovieDetail
+	PosterURL   string `json:"poster_url,omitempty"`
+	BackdropURL string `json:"backdrop_url,omitempty"`
+}
+
+type imageRecord struct {
+	Type string `json:"type"`
+	tmdb.Image
+	URL string `json:"url"`
+}
+
+type videoRecord struct {
+	tmdb.Video
+	URL string `json:"url,omitempty"`
+}
+
+func runMovie(client *persistent.Client, opts *options, args []string) error {
+	fs := newFlagSet(opts, "movie", "<id> [credits|images|videos]")
+	crew := fs.Bool("crew", false, "credits: list the crew instead of the cast")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return usagef("expected a movie ID, optionally followed by credits, images or videos")
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		detail, err := client.GetMovieDetail(id, &tmdb.MovieDetailRequest{Language: opts.language})
+		if err != nil {
+			return err
+		}
+		return opts.print(&result{
+			value: &movieDetail{
+				MovieDetail: detail,
+				PosterURL:   client.GetImage(detail.PosterPath, ""),
+				BackdropURL: client.GetImage(detail.BackdropPath, ""),
+			},
+			columns: []string{"id", "title", "original_title", "tagline", "release_date", "status", "runtime", "genres", "vote_average", "imdb_id", "homepage", "poster_url", "overview"},
+		})
+	}
+	switch args[1] {
+	case "credits":
+		credits, err := client.GetMovieCredits(id, &tmdb.MovieCreditsRequest{Language: opts.language})
+		if err != nil {
+			return err
+		}
+		return opts.printCredits(credits, *crew)
+	case "images":
+		images, err := client.GetMovieImages(id, &tmdb.ImagesRequest{Language: opts.language, IncludeImageLanguage: "null"})
+		if err != nil {
+			return err
+		}
+		return opts.printImages(client, images)
+	case "videos":
+		videos, err := client.GetMovieVideos(id, &tmdb.MovieVideosRequest{Language: opts.language})
+		if err != nil {
+			return err
+		}
+		var records []videoRecord
+		for _, video := range videos.Results {
+			records = append(records, videoRecord{Video: video, URL: videoURL(video)})
+		}
+		return opts.print(&result{
+			value:   videos,
+			records: records,
+			columns: []string{"type", "name", "site", "url"},
+		})
+	}
+	return usagef("unknown movie subcommand %q, expected credits, images or videos", args[1])
+}
+
+func (opts *options) printCredits(credits *tmdb.MovieCredits, crew bool) error {
+	if crew {
+		return opts.print(&result{
+			value:   credits,
+			records: credits.Crew,
+			columns: []string{"id", "name", "department", "job"},
+		})
+	}
+	return opts.print(&result{
+		value:   credits,
+		records: credits.Cast,
+		columns: []string{"id", "name", "character"},
+	})
+}
+
+func (opts *options) printImages(client *persistent.Client, images *tmdb.Images) error {
+	var records []imageRecord
+	for _, kind := range []struct {
+		name   string
+		images []tmdb.Image
+	}{
+		{"poster", images.Posters},
+		{"backdrop", images.Backdrops},
+		{"logo", images.Logos},
+	} {
+		for _, image := range kind.images {
+			records = append(records, imageRecord{Type: kind.name, Image: image, URL: client.GetImage(image.FilePath, "")})
+		}
+	}
+	return opts.print(&result{
+		value:   images,
+		records: records,
+		columns: []string{"type", "iso_639_1", "width", "height", "vote_average", "url"},
+	})
+}
+
+func videoURL(video tmdb.Video) string {
+	switch video.Site {
+	case "YouTube":
+		return "https://www.youtube.com/watch?v=" + video.Key
+	case "Vimeo":
+		return "https://vimeo.com/" + video.Key
+	}
+	return ""
+}
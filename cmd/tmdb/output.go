@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var stdout io.Writer = os.Stdout
+
+// Output formats.
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+	formatYAML   = "yaml"
+)
+
+// result is what a command prints. Records are addressed by their JSON
+// field names, both for column selection and in the structured formats.
+type result struct {
+	// value is the whole typed response, printed by json and yaml and
+	// given to templates when there are no records.
+	value any
+	// records is a slice of the rows of a list, nil for a single object.
+	// table, csv, ndjson and templates work on the records.
+	records any
+	// columns are the fields shown by table and csv unless -columns
+	// selects others.
+	columns []string
+}
+
+// print renders r in the format selected by the flags.
+func (opts *options) print(r *result) error {
+	if opts.template != "" {
+		return opts.printTemplate(r)
+	}
+	switch opts.output {
+	case formatTable, "":
+		return opts.printTable(r)
+	case formatCSV:
+		return opts.printCSV(r)
+	case formatJSON:
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.value)
+	case formatNDJSON:
+		enc := json.NewEncoder(stdout)
+		for _, record := range records(r) {
+			if err := enc.Encode(record.Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatYAML:
+		return printYAML(r.value)
+	}
+	return usagef("unknown output format %q", opts.output)
+}
+
+func (opts *options) selected(r *result) []string {
+	switch opts.columns {
+	case "":
+		return r.columns
+	case "all":
+		var all []string
+		if rs := records(r); len(rs) > 0 {
+			for _, f := range fields(rs[0]) {
+				all = append(all, f.name)
+			}
+		}
+		return all
+	}
+	columns := strings.Split(opts.columns, ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	return columns
+}
+
+// printTable prints lists as aligned columns under a header and single
+// objects as aligned "name: value" lines.
+func (opts *options) printTable(r *result) error {
+	columns := opts.selected(r)
+	rs := records(r)
+	if r.records == nil && len(rs) == 1 {
+		w := tabwriter.NewWriter(stdout, 0, 0, 1, ' ', 0)
+		values := fieldMap(rs[0])
+		for _, column := range columns {
+			if value := format(values[column]); value != "" {
+				fmt.Fprintf(w, "%s:\t%s\n", column, value)
+			}
+		}
+		return w.Flush()
+	}
+	if len(rs) == 0 {
+		fmt.Fprintln(os.Stderr, "no results")
+		return nil
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, record := range rs {
+		values := fieldMap(record)
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = strings.ReplaceAll(format(values[column]), "\t", " ")
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func (opts *options) printCSV(r *result) error {
+	columns := opts.selected(r)
+	w := csv.NewWriter(stdout)
+	w.Write(columns)
+	for _, record := range records(r) {
+		values := fieldMap(record)
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = format(values[column])
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// printTemplate executes the template once per record, or once on the
+// value of single objects. Fields are addressed by their Go names, as in
+// {{.Title}}.
+func (opts *options) printTemplate(r *result) error {
+	text := opts.template
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("output").Funcs(template.FuncMap{
+		"join": strings.Join,
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return usagef("invalid template: %s", err)
+	}
+	for _, record := range records(r) {
+		if err := tmpl.Execute(stdout, record.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printYAML prints v with the same field names and order as JSON, by
+// reading its JSON encoding as a YAML document.
+func printYAML(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	enc := yaml.NewEncoder(stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle drops the flow style that JSON input leaves on every node.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// records returns the records of r, or r.value as the only record.
+func records(r *result) (list []reflect.Value) {
+	if r.records == nil {
+		return []reflect.Value{reflect.ValueOf(r.value)}
+	}
+	v := reflect.ValueOf(r.records)
+	for i := 0; i < v.Len(); i++ {
+		list = append(list, v.Index(i))
+	}
+	return
+}
+
+type namedValue struct {
+	name  string
+	value reflect.Value
+}
+
+// fields flattens a struct into its JSON fields, including those of
+// embedded structs.
+func fields(v reflect.Value) (list []namedValue) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			list = append(list, fields(v.Field(i))...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		list = append(list, namedValue{name, v.Field(i)})
+	}
+	return
+}
+
+func fieldMap(v reflect.Value) map[string]reflect.Value {
+	m := make(map[string]reflect.Value)
+	for _, f := range fields(v) {
+		m[f.name] = f.value
+	}
+	return m
+}
+
+// format renders a field for table and csv cells. Lists are joined with
+// commas, and objects are shown by their name when they have one.
+func format(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+		var items []string
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, format(v.Index(i)))
+		}
+		return strings.Join(items, ", ")
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Local().Format(time.DateTime)
+		}
+		if t, ok := v.Interface().(fmt.Stringer); ok {
+			return t.String()
+		}
+		values := fieldMap(v)
+		for _, name := range []string{"name", "title"} {
+			if value, ok := values[name]; ok {
+				return format(value)
+			}
+		}
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(v.Interface())
+		return strings.TrimSpace(buf.String())
+	case reflect.Map:
+		data, _ := json.Marshal(v.Interface())
+		return string(data)
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/song940/tmdb-go/tmdb"
+	"gopkg.in/yaml.v3"
+)
+
+// runOutput runs a command with table output by default, and returns what it
+// printed.
+func runOutput(t *testing.T, cmd command, args ...string) string {
+	t.Helper()
+	client, _ := newTestClient(t)
+	out := captureStdout(t)
+	if err := cmd(client, &options{output: formatTable}, args); err != nil {
+		t.Fatalf("%q: %v", args, err)
+	}
+	return out.String()
+}
+
+// squeeze collapses the runs of spaces that align table columns.
+func squeeze(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestOutputTable(t *testing.T) {
+	got := runOutput(t, runSearch, "movie", "matrix")
+	want := "ID TITLE RELEASE_DATE ORIGINAL_TITLE\n" +
+		"603 The Matrix 1999-03-30 The Matrix\n" +
+		"604 The Matrix Reloaded 2003-05-15 The Matrix Reloaded"
+	if squeeze(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Single objects are printed as name: value lines, with lists of
+	// objects by name.
+	got = runOutput(t, runMovie, "603")
+	for _, line := range []string{"id: 603", "title: The Matrix", "runtime: 136", "genres: Action, Science Fiction", "imdb_id: tt0133093"} {
+		if !strings.Contains(squeeze(got), line) {
+			t.Errorf("table lacks %q:\n%s", line, got)
+		}
+	}
+}
+
+func TestOutputCSV(t *testing.T) {
+	got := runOutput(t, runSearch, "movie", "matrix", "-output", "csv")
+	want := "id,title,release_date,original_title\n" +
+		"603,The Matrix,1999-03-30,The Matrix\n" +
+		"604,The Matrix Reloaded,2003-05-15,The Matrix Reloaded\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOutputColumns(t *testing.T) {
+	got := runOutput(t, runSearch, "movie", "matrix", "-output", "csv", "-columns", "title, vote_average,missing")
+	want := "title,vote_average,missing\nThe Matrix,8.2,\nThe Matrix Reloaded,7.1,\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	got = runOutput(t, runSearch, "movie", "matrix", "-columns", "all")
+	header := strings.Fields(strings.SplitN(got, "\n", 2)[0])
+	for _, column := range []string{"ID", "TITLE", "POPULARITY", "VOTE_COUNT", "GENRE_IDS"} {
+		if !strings.Contains(strings.Join(header, " "), column) {
+			t.Errorf("-columns all header %v lacks %s", header, column)
+		}
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	got := runOutput(t, runSearch, "movie", "matrix", "-output", "json")
+	var res tmdb.SearchMovieResponse
+	if err := json.Unmarshal([]byte(got), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Page != 1 || len(res.Results) != 2 || res.Results[1].Title != "The Matrix Reloaded" {
+		t.Errorf("decoded %+v", res)
+	}
+	if !strings.HasPrefix(got, "{\n  \"page\": 1,") {
+		t.Errorf("JSON not indented:\n%s", got)
+	}
+}
+
+func TestOutputNDJSON(t *testing.T) {
+	got := runOutput(t, runSearch, "movie", "matrix", "-output", "ndjson")
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per result:\n%s", len(lines), got)
+	}
+	for i, want := range []string{"The Matrix", "The Matrix Reloaded"} {
+		var movie tmdb.MovieObject
+		if err := json.Unmarshal([]byte(lines[i]), &movie); err != nil {
+			t.Fatal(err)
+		}
+		if movie.Title != want {
+			t.Errorf("line %d has %q, want %q", i, movie.Title, want)
+		}
+	}
+}
+
+func TestOutputYAML(t *testing.T) {
+	got := runOutput(t, runMovie, "603", "-output", "yaml")
+	var detail struct {
+		ID     int    `yaml:"id"`
+		Title  string `yaml:"title"`
+		IMDbID string `yaml:"imdb_id"`
+		Genres []struct {
+			Name string `yaml:"name"`
+		} `yaml:"genres"`
+		PosterURL string `yaml:"poster_url"`
+	}
+	if err := yaml.Unmarshal([]byte(got), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.ID != 603 || detail.Title != "The Matrix" || detail.IMDbID != "tt0133093" || len(detail.Genres) != 2 || detail.PosterURL == "" {
+		t.Errorf("decoded %+v", detail)
+	}
+	// Block style, in the field order of JSON.
+	if strings.Contains(got, "{") || strings.Index(got, "adult:") > strings.Index(got, "title:") {
+		t.Errorf("got\n%s", got)
+	}
+}
+
+func TestOutputTemplate(t *testing.T) {
+	for _, test := range []struct {
+		cmd  command
+		args []string
+		want string
+	}{
+		{runSearch, []string{"movie", "matrix", "-template", "{{.ID}} {{.Title}} ({{.ReleaseDate}})"}, "603 The Matrix (1999-03-30)\n604 The Matrix Reloaded (2003-05-15)\n"},
+		{runMovie, []string{"603", "-template", "{{.Title}}: {{.Runtime}} min\n"}, "The Matrix: 136 min\n"},
+		{runMovie, []string{"603", "-template", `{{json .Genres}}`}, `[{"id":28,"name":"Action"},{"id":878,"name":"Science Fiction"}]` + "\n"},
+	} {
+		if got := runOutput(t, test.cmd, test.args...); got != test.want {
+			t.Errorf("%q printed %q, want %q", test.args, got, test.want)
+		}
+	}
+
+	client, _ := newTestClient(t)
+	captureStdout(t)
+	var usageErr *usageError
+	if err := runSearch(client, &options{output: formatTable}, []string{"movie", "matrix", "-template", "{{.Title"}); !errors.As(err, &usageErr) {
+		t.Errorf("invalid template: got %v, want a usage error", err)
+	}
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/song940/tmdb-go/match"
+	"github.com/song940/tmdb-go/persistent"
+)
+
+const (
+	defaultMovieTemplate = "{title} ({year})/{title} ({year}).{ext}"
+	defaultTVTemplate    = "{show}/Season {season:02}/{show} - S{season:02}E{episode:02} - {episode_title}.{ext}"
+	defaultRenameLog     = ".tmdb-rename.log"
+)
+
+var (
+	videoExts   = map[string]bool{"mkv": true, "mp4": true, "m4v": true, "avi": true, "mov": true, "wmv": true, "ts": true, "m2ts": true, "webm": true, "mpg": true, "mpeg": true}
+	sidecarExts = map[string]bool{"srt": true, "ass": true, "ssa": true, "sub": true, "idx": true, "vtt": true, "sup": true, "nfo": true}
+)
+
+// renameRecord is one planned or performed move. It is also the format of
+// the undo log, one JSON object per line.
+type renameRecord struct {
+	Kind       string    `json:"kind"`
+	From       string    `json:"from"`
+	To         string    `json:"to,omitempty"`
+	Confidence float64   `json:"confidence,omitempty"`
+	Status     string    `json:"status"`
+	Time       time.Time `json:"time,omitempty"`
+}
+
+func runRename(client *persistent.Client, opts *options, args []string) error {
+	fs := newFlagSet(opts, "rename", "[flags] <file or directory>...")
+	movieTemplate := fs.String("movie-template", defaultMovieTemplate, "`template` for movies")
+	tvTemplate := fs.String("tv-template", defaultTVTemplate, "`template` for episodes")
+	dest := fs.String("dest", ".", "library `directory` the templates are relative to")
+	dryRun := fs.Bool("dry-run", false, "show what would be renamed without touching any file")
+	conflict := fs.String("conflict", "skip", "when a target exists: skip leaves the file, overwrite replaces the target after moving it to <target>.bak, number adds \" (2)\" to the name")
+	minConfidence := fs.Float64("min-confidence", 0.6, "leave files matched with a lower confidence alone")
+	logFile := fs.String("log", "", "undo log `file` (default: "+defaultRenameLog+" in the library directory)")
+	undo := fs.Bool("undo", false, "move the files recorded in the undo log back")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	switch *conflict {
+	case "skip", "overwrite", "number":
+	default:
+		return usagef("unknown conflict mode %q, expected skip, overwrite or number", *conflict)
+	}
+	if *logFile == "" {
+		*logFile = filepath.Join(*dest, defaultRenameLog)
+	}
+	r := &renamer{
+		client:        client,
+		opts:          opts,
+		templates:     map[bool]string{false: *movieTemplate, true: *tvTemplate},
+		dest:          *dest,
+		dryRun:        *dryRun,
+		conflict:      *conflict,
+		minConfidence: *minConfidence,
+		logFile:       *logFile,
+		targets:       make(map[string]bool),
+	}
+	if *undo {
+		return r.undo()
+	}
+	if len(args) == 0 {
+		return usagef("expected files or directories to rename")
+	}
+	for _, tmpl := range r.templates {
+		if _, err := expandTemplate(tmpl, templateValues{}); err != nil {
+			return usagef("%s", err)
+		}
+	}
+	var files []string
+	for _, arg := range args {
+		found, err := videos(arg)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	for _, file := range files {
+		r.rename(file)
+	}
+	return r.finish()
+}
+
+type renamer struct {
+	client        *persistent.Client
+	opts          *options
+	templates     map[bool]string
+	dest          string
+	dryRun        bool
+	conflict      string
+	minConfidence float64
+	logFile       string
+
+	// targets are the paths planned so far, so that two files of a dry
+	// run do not claim the same target unnoticed.
+	targets map[string]bool
+	records []renameRecord
+	failed  int
+}
+
+// videos returns the video files at path, walking directories.
+func videos(path string) (files []string, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	err = filepath.WalkDir(path, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && filename != path && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !entry.IsDir() && videoExts[extOf(filename)] {
+			files = append(files, filename)
+		}
+		return nil
+	})
+	return
+}
+
+func (r *renamer) rename(file string) {
+	record := renameRecord{Kind: "video", From: file}
+	res, err := match.File(r.client, file, &match.Options{Language: r.opts.language})
+	switch {
+	case errors.Is(err, match.ErrNoMatch):
+		record.Status = "skipped: no match"
+	case err != nil:
+		record.Status = "failed: " + err.Error()
+		r.failed++
+	case res.Best.Confidence < r.minConfidence:
+		record.Confidence = res.Best.Confidence
+		record.Status = fmt.Sprintf("skipped: low confidence, best match %q", res.Best.Title())
+	case res.Best.TV != nil && len(res.Episodes) == 0:
+		record.Confidence = res.Best.Confidence
+		record.Status = fmt.Sprintf("skipped: episode not found in %q", res.Best.Title())
+	}
+	if record.Status != "" {
+		r.records = append(r.records, record)
+		return
+	}
+	record.Confidence = res.Best.Confidence
+	target, err := expandTemplate(r.templates[res.Best.TV != nil], valuesOf(res, extOf(file)))
+	if err != nil {
+		record.Status = "failed: " + err.Error()
+		r.failed++
+		r.records = append(r.records, record)
+		return
+	}
+	record.To = filepath.Join(r.dest, filepath.FromSlash(target))
+	sidecars := sidecarsOf(file)
+	if !r.move(&record) {
+		return
+	}
+	// Sidecars keep what follows the name of the video, such as the
+	// language of "Movie.2019.en.srt".
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	targetBase := strings.TrimSuffix(record.To, filepath.Ext(record.To))
+	for _, sidecar := range sidecars {
+		r.move(&renameRecord{
+			Kind: "sidecar",
+			From: sidecar,
+			To:   targetBase + strings.TrimPrefix(sidecar, base),
+		})
+	}
+}
+
+// move moves record.From to record.To, resolving conflicts, and records
+// the outcome. It reports whether the file was, or would be, moved.
+func (r *renamer) move(record *renameRecord) bool {
+	defer func() { r.records = append(r.records, *record) }()
+	if same(record.From, record.To) {
+		record.Status = "unchanged"
+		return false
+	}
+	if r.exists(record.To) {
+		switch r.conflict {
+		case "skip":
+			record.Status = "skipped: target exists"
+			return false
+		case "overwrite":
+			if err := r.backup(record.To); err != nil {
+				record.Status = "failed: backup: " + err.Error()
+				r.failed++
+				return false
+			}
+		case "number":
+			ext := filepath.Ext(record.To)
+			base := strings.TrimSuffix(record.To, ext)
+			for n := 2; r.exists(record.To); n++ {
+				record.To = fmt.Sprintf("%s (%d)%s", base, n, ext)
+			}
+		}
+	}
+	r.targets[record.To] = true
+	if r.dryRun {
+		record.Status = "would rename"
+		return true
+	}
+	if err := moveFile(record.From, record.To); err != nil {
+		record.Status = "failed: " + err.Error()
+		r.failed++
+		return false
+	}
+	record.Status = "renamed"
+	record.Time = time.Now()
+	if err := appendLog(r.logFile, record); err != nil {
+		record.Status = "renamed, not logged: " + err.Error()
+		r.failed++
+	}
+	return true
+}
+
+// backup moves the target of an overwrite aside, to target.bak, and logs
+// the move, so that undo puts it back once the new file is moved away.
+func (r *renamer) backup(target string) error {
+	record := renameRecord{Kind: "backup", From: target, To: target + ".bak"}
+	for n := 2; r.exists(record.To); n++ {
+		record.To = fmt.Sprintf("%s.%d.bak", target, n)
+	}
+	if r.dryRun {
+		record.Status = "would back up"
+		r.targets[record.To] = true
+		r.records = append(r.records, record)
+		return nil
+	}
+	if _, err := os.Lstat(target); errors.Is(err, fs.ErrNotExist) {
+		// Planned by a move that failed.
+		return nil
+	}
+	if err := moveFile(record.From, record.To); err != nil {
+		return err
+	}
+	record.Status = "backed up"
+	record.Time = time.Now()
+	if err := appendLog(r.logFile, &record); err != nil {
+		// Undo could not restore it.
+		moveFile(record.To, record.From)
+		return err
+	}
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *renamer) exists(path string) bool {
+	if r.targets[path] {
+		return true
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (r *renamer) finish() error {
+	if err := r.opts.print(&result{
+		value:   r.records,
+		records: r.records,
+		columns: []string{"kind", "from", "to", "status"},
+	}); err != nil {
+		return err
+	}
+	if r.failed > 0 {
+		return fmt.Errorf("%d of %d files failed", r.failed, len(r.records))
+	}
+	return nil
+}
+
+// undo moves the files of the undo log back, newest first. Entries that
+// cannot be undone are kept in the log, the log is removed otherwise.
+func (r *renamer) undo() error {
+	logged, err := readLog(r.logFile)
+	if err != nil {
+		return err
+	}
+	var kept []renameRecord
+	for i := len(logged) - 1; i >= 0; i-- {
+		entry := logged[i]
+		record := renameRecord{Kind: entry.Kind, From: entry.To, To: entry.From}
+		switch {
+		case !r.dryRun && r.exists(record.To):
+			record.Status = "skipped: original path exists"
+		case r.dryRun:
+			record.Status = "would restore"
+		default:
+			if err := moveFile(record.From, record.To); err != nil {
+				record.Status = "failed: " + err.Error()
+			} else {
+				record.Status = "restored"
+				removeEmptyDirs(filepath.Dir(record.From), r.dest)
+			}
+		}
+		if record.Status != "restored" && !r.dryRun {
+			kept = append([]renameRecord{entry}, kept...)
+			r.failed++
+		}
+		r.records = append(r.records, record)
+	}
+	if !r.dryRun {
+		if err := writeLog(r.logFile, kept); err != nil {
+			return err
+		}
+	}
+	return r.finish()
+}
+
+// sidecarsOf returns the subtitles and metadata files next to a video that
+// share its name.
+func sidecarsOf(file string) (list []string) {
+	base := filepath.Base(strings.TrimSuffix(file, filepath.Ext(file)))
+	entries, err := os.ReadDir(filepath.Dir(file))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, base+".") && sidecarExts[extOf(name)] {
+			list = append(list, filepath.Join(filepath.Dir(file), name))
+		}
+	}
+	return
+}
+
+// moveFile renames from to to, creating directories as needed and
+// falling back to copying across file systems.
+func moveFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	err := os.Rename(from, to)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err == nil {
+		err = dst.Sync()
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(to)
+		return err
+	}
+	os.Chtimes(to, info.ModTime(), info.ModTime())
+	return os.Remove(from)
+}
+
+// removeEmptyDirs removes dir and its parents while they are empty, up to
+// but excluding root.
+func removeEmptyDirs(dir, root string) {
+	root, _ = filepath.Abs(root)
+	for {
+		abs, err := filepath.Abs(dir)
+		if err != nil || abs == root || !strings.HasPrefix(abs, root+string(filepath.Separator)) {
+			return
+		}
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func same(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
+
+// appendLog records a move with absolute paths, so that it can be undone
+// from any directory.
+func appendLog(filename string, record *renameRecord) error {
+	entry := *record
+	entry.From, _ = filepath.Abs(entry.From)
+	entry.To, _ = filepath.Abs(entry.To)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(&entry)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func readLog(filename string) (list []renameRecord, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var record renameRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, line, err)
+		}
+		list = append(list, record)
+	}
+	err = scanner.Err()
+	return
+}
+
+func writeLog(filename string, list []renameRecord) error {
+	if len(list) == 0 {
+		return os.Remove(filename)
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	for i := range list {
+		enc.Encode(&list[i])
+	}
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
+
+// templateValues are the placeholders of rename templates. Numbers are
+// lists so that multi-episode files render as "01-02".
+type templateValues struct {
+	strings map[string]string
+	numbers map[string][]int
+}
+
+func valuesOf(res *match.Result, ext string) templateValues {
+	v := templateValues{
+		strings: map[string]string{"ext": ext, "title": res.Best.Title()},
+		numbers: map[string][]int{"id": {res.Best.ID()}},
+	}
+	if year := res.Best.Year(); year > 0 {
+		v.numbers["year"] = []int{year}
+	}
+	if movie := res.Best.Movie; movie != nil {
+		v.strings["original_title"] = movie.OriginalTitle
+	}
+	if tv := res.Best.TV; tv != nil {
+		v.strings["show"] = tv.Name
+		v.strings["original_title"] = tv.OriginalName
+		var names []string
+		for _, episode := range res.Episodes {
+			v.numbers["episode"] = append(v.numbers["episode"], episode.Episode)
+			names = append(names, episode.Name)
+		}
+		v.numbers["season"] = []int{res.Episodes[0].Season}
+		v.strings["episode_title"] = strings.Join(names, " & ")
+		v.strings["air_date"] = res.Episodes[0].AirDate
+		v.numbers["absolute"] = res.Media.Absolute
+	}
+	return v
+}
+
+var (
+	placeholder = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`)
+	// emptyParts are the leftovers of placeholders without a value, as
+	// in "Title ()".
+	emptyParts = regexp.MustCompile(`\(\s*\)|\[\s*\]| - (?:\.|$)`)
+	unsafe     = strings.NewReplacer(":", " -", "/", "-", "\\", "-", "<", "", ">", "", "\"", "", "|", "", "?", "", "*", "")
+)
+
+var templateNames = map[string]bool{
+	"title": true, "original_title": true, "year": true, "id": true, "ext": true,
+	"show": true, "season": true, "episode": true, "episode_title": true, "air_date": true, "absolute": true,
+}
+
+// expandTemplate fills a template such as "{title} ({year})/{title}.{ext}".
+// Numbers take a zero-padded width as in {season:02}. Values are made safe
+// for file names, so only the template creates directories.
+func expandTemplate(tmpl string, v templateValues) (string, error) {
+	var err error
+	out := placeholder.ReplaceAllStringFunc(tmpl, func(s string) string {
+		m := placeholder.FindStringSubmatch(s)
+		name, width := m[1], m[2]
+		if !templateNames[name] {
+			err = fmt.Errorf("unknown placeholder {%s} in template %q", name, tmpl)
+			return ""
+		}
+		if numbers, ok := v.numbers[name]; ok {
+			parts := make([]string, len(numbers))
+			w, _ := strconv.Atoi(width)
+			for i, n := range numbers {
+				parts[i] = fmt.Sprintf("%0*d", w, n)
+			}
+			return strings.Join(parts, "-")
+		}
+		return strings.TrimRight(strings.TrimSpace(unsafe.Replace(v.strings[name])), ".")
+	})
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(out, "/")
+	for i, part := range parts {
+		part = emptyParts.ReplaceAllStringFunc(part, func(s string) string {
+			// " - ." keeps its dot, empty brackets go.
+			rest, _ := strings.CutPrefix(s, " - ")
+			if rest == s {
+				return ""
+			}
+			return rest
+		})
+		parts[i] = strings.ReplaceAll(strings.Join(strings.Fields(part), " "), " .", ".")
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+func extOf(filename string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	movie := templateValues{
+		strings: map[string]string{"title": "Mission: Impossible", "ext": "mkv"},
+		numbers: map[string][]int{"year": {1996}, "id": {954}},
+	}
+	episodes := templateValues{
+		strings: map[string]string{"show": "Game of Thrones", "episode_title": "Winter Is Coming & The Kingsroad", "ext": "ts"},
+		numbers: map[string][]int{"season": {1}, "episode": {1, 2}},
+	}
+	for _, test := range []struct {
+		tmpl string
+		v    templateValues
+		want string
+		err  bool
+	}{
+		{defaultMovieTemplate, movie, "Mission - Impossible (1996)/Mission - Impossible (1996).mkv", false},
+		{"{title} [{id}].{ext}", movie, "Mission - Impossible [954].mkv", false},
+		{defaultTVTemplate, episodes, "Game of Thrones/Season 01/Game of Thrones - S01E01-02 - Winter Is Coming & The Kingsroad.ts", false},
+		// Placeholders without a value leave no empty brackets behind.
+		{"{title} ({year}) - {air_date}.{ext}", templateValues{strings: map[string]string{"title": "Heat", "ext": "mkv"}}, "Heat.mkv", false},
+		{"{title}/{title}.{ext}", templateValues{strings: map[string]string{"title": "AC/DC: Live", "ext": "mkv"}}, "AC-DC - Live/AC-DC - Live.mkv", false},
+		{"{name}.{ext}", movie, "", true},
+	} {
+		got, err := expandTemplate(test.tmpl, test.v)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("expandTemplate(%q) = %q, %v, want %q", test.tmpl, got, err, test.want)
+		}
+	}
+}
+
+// tree returns the files under dir with their contents, as "path=content".
+func tree(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || entry.Name() == defaultRenameLog {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel)+"="+string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	const matrix = "lib/The Matrix (1999)/The Matrix (1999)"
+	source := map[string]string{
+		"in/The.Matrix.1999.1080p.BluRay.x264.mkv":    "matrix",
+		"in/The.Matrix.1999.1080p.BluRay.x264.en.srt": "subtitles",
+		"in/The.Matrix.1999.1080p.BluRay.x264.txt":    "not a sidecar",
+		"in/Game.of.Thrones.S01E01.720p.HDTV.ts":      "got",
+	}
+	for _, test := range []struct {
+		name     string
+		args     []string
+		existing map[string]string
+		want     []string
+	}{
+		{
+			name: "new library",
+			want: []string{
+				"in/The.Matrix.1999.1080p.BluRay.x264.txt=not a sidecar",
+				"lib/Game of Thrones/Season 01/Game of Thrones - S01E01 - Winter Is Coming.ts=got",
+				matrix + ".en.srt=subtitles",
+				matrix + ".mkv=matrix",
+			},
+		},
+		{
+			name:     "skip",
+			args:     []string{"-conflict", "skip"},
+			existing: map[string]string{matrix + ".mkv": "old"},
+			want: []string{
+				"in/The.Matrix.1999.1080p.BluRay.x264.en.srt=subtitles",
+				"in/The.Matrix.1999.1080p.BluRay.x264.mkv=matrix",
+				"in/The.Matrix.1999.1080p.BluRay.x264.txt=not a sidecar",
+				"lib/Game of Thrones/Season 01/Game of Thrones - S01E01 - Winter Is Coming.ts=got",
+				matrix + ".mkv=old",
+			},
+		},
+		{
+			name:     "overwrite",
+			args:     []string{"-conflict", "overwrite"},
+			existing: map[string]string{matrix + ".mkv": "old"},
+			want: []string{
+				"in/The.Matrix.1999.1080p.BluRay.x264.txt=not a sidecar",
+				"lib/Game of Thrones/Season 01/Game of Thrones - S01E01 - Winter Is Coming.ts=got",
+				matrix + ".en.srt=subtitles",
+				matrix + ".mkv.bak=old",
+				matrix + ".mkv=matrix",
+			},
+		},
+		{
+			name:     "number",
+			args:     []string{"-conflict", "number"},
+			existing: map[string]string{matrix + ".mkv": "old"},
+			want: []string{
+				"in/The.Matrix.1999.1080p.BluRay.x264.txt=not a sidecar",
+				"lib/Game of Thrones/Season 01/Game of Thrones - S01E01 - Winter Is Coming.ts=got",
+				matrix + " (2).en.srt=subtitles",
+				matrix + " (2).mkv=matrix",
+				matrix + ".mkv=old",
+			},
+		},
+		{
+			name:     "dry run",
+			args:     []string{"-dry-run", "-conflict", "overwrite"},
+			existing: map[string]string{matrix + ".mkv": "old"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			out := captureStdout(t)
+			dir := t.TempDir()
+			writeFiles(t, dir, source)
+			writeFiles(t, dir, test.existing)
+			before := tree(t, dir)
+			lib := filepath.Join(dir, "lib")
+			args := append([]string{"-dest", lib}, test.args...)
+			if err := runRename(client, &options{}, append(args, filepath.Join(dir, "in"))); err != nil {
+				t.Fatalf("%v\n%s", err, out)
+			}
+			want := test.want
+			if want == nil {
+				want = before
+			}
+			if got := tree(t, dir); strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("after rename:\n%s\nwant:\n%s\noutput:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"), out)
+			}
+			if test.want == nil {
+				for _, status := range []string{"would back up", "would rename"} {
+					if !strings.Contains(out.String(), status) {
+						t.Errorf("dry run does not report %q:\n%s", status, out)
+					}
+				}
+				return
+			}
+			if err := runRename(client, &options{}, []string{"-dest", lib, "-undo"}); err != nil {
+				t.Fatalf("undo: %v\n%s", err, out)
+			}
+			if got := tree(t, dir); strings.Join(got, "\n") != strings.Join(before, "\n") {
+				t.Errorf("after undo:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(before, "\n"))
+			}
+			if _, err := os.Stat(filepath.Join(lib, defaultRenameLog)); !os.IsNotExist(err) {
+				t.Errorf("undo log left behind: %v", err)
+			}
+		})
+	}
+}
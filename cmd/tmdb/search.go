@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/song940/tmdb-go/persistent"
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+func runSearch(client *persistent.Client, opts *options, args []string) error {
+	fs := newFlagSet(opts, "search", "movie|tv|person [flags] <query>")
+	year := fs.String("year", "", "only return results from this `year`")
+	page := fs.Int("page", 1, "page of results to show")
+	adult := fs.Bool("adult", false, "include adult results")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return usagef("expected movie, tv or person followed by a query")
+	}
+	query := strings.Join(args[1:], " ")
+	switch args[0] {
+	case "movie":
+		res, err := client.SearchMovie(query, &tmdb.SearchMovieRequest{
+			Language:     opts.language,
+			Page:         int32(*page),
+			Year:         *year,
+			IncludeAdult: *adult,
+		})
+		if err != nil {
+			return err
+		}
+		return opts.printPage(res.Page, res.TotalPages, res.TotalResults, &result{
+			value:   res,
+			records: res.Results,
+			columns: []string{"id", "title", "release_date", "original_title"},
+		})
+	case "tv":
+		res, err := client.SearchTV(query, &tmdb.SearchTVRequest{
+			Language:         opts.language,
+			Page:             int32(*page),
+			FirstAirDateYear: *year,
+			IncludeAdult:     *adult,
+		})
+		if err != nil {
+			return err
+		}
+		return opts.printPage(res.Page, res.TotalPages, res.TotalResults, &result{
+			value:   res,
+			records: res.Results,
+			columns: []string{"id", "name", "first_air_date", "original_name"},
+		})
+	case "person":
+		res, err := client.SearchPerson(query, &tmdb.SearchPersonRequest{
+			Language:     opts.language,
+			Page:         int32(*page),
+			IncludeAdult: *adult,
+		})
+		if err != nil {
+			return err
+		}
+		return opts.printPage(res.Page, res.TotalPages, res.TotalResults, &result{
+			value:   res,
+			records: res.Results,
+			columns: []string{"id", "name", "known_for_department", "known_for"},
+		})
+	}
+	return usagef("cannot search %q, expected movie, tv or person", args[0])
+}
+
+// printPage prints a page of search results, followed in table output by
+// a paging summary on stderr, which keeps stdout easy to process.
+func (opts *options) printPage(page, totalPages, totalResults int, r *result) error {
+	if err := opts.print(r); err != nil {
+		return err
+	}
+	if opts.output == formatTable && opts.template == "" && totalResults > 0 {
+		fmt.Fprintf(os.Stderr, "page %d of %d, %d results\n", page, totalPages, totalResults)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/song940/tmdb-go/persistent"
+)
+
+func runServe(client *persistent.Client, opts *options, args []string) error {
+	fs := newFlagSet(opts, "serve", "[flags]")
+	addr := fs.String("addr", "localhost:8080", "listen `address`")
+	prefix := fs.String("prefix", "/3", "`path` the API is served under")
+	rateLimit := fs.Float64("rate", 0, "requests per second allowed to each client address, 0 for no limit")
+	burst := fs.Int("burst", 0, "requests a client may send at once (default: the rate rounded up)")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return usagef("unexpected argument %q", args[0])
+	}
+	if client.APIKey == "" && client.AccessToken == "" && !opts.offline {
+		return errors.New("no API key or access token configured")
+	}
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	proxy := persistent.NewProxy(client, &persistent.ProxyOptions{
+		Prefix:    *prefix,
+		RateLimit: *rateLimit,
+		Burst:     *burst,
+	})
+	server := &http.Server{Handler: proxy, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+	fmt.Fprintf(os.Stderr, "serving the TMDB API on http://%s%s\n", listener.Addr(), *prefix)
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"github.com/song940/tmdb-go/persistent"
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+// tvDetail adds full image URLs to tmdb.TVDetail.
+type tvDetail struct {
+	*tmdb.TVDetail
+	PosterURL   string `json:"poster_url,omitempty"`
+	BackdropURL string `json:"backdrop_url,omitempty"`
+}
+
+// tvEpisode adds the full still URL to tmdb.TVEpisodeDetail.
+type tvEpisode struct {
+	*tmdb.TVEpisodeDetail
+	StillURL string `json:"still_url,omitempty"`
+}
+
+func runTV(client *persistent.Client, opts *options, args []string) error {
+	fs := newFlagSet(opts, "tv", "<id> [credits | season <n> [episode <m>]]")
+	crew := fs.Bool("crew", false, "credits: list the crew instead of the cast")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return usagef("expected a TV series ID")
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	lang := &tmdb.TVDetailRequest{Language: opts.language}
+	switch {
+	case len(args) == 1:
+		detail, err := client.GetTVDetail(id, lang)
+		if err != nil {
+			return err
+		}
+		return opts.print(&result{
+			value: &tvDetail{
+				TVDetail:    detail,
+				PosterURL:   client.GetImage(detail.PosterPath, ""),
+				BackdropURL: client.GetImage(detail.BackdropPath, ""),
+			},
+			columns: []string{"id", "name", "original_name", "tagline", "first_air_date", "last_air_date", "status", "number_of_seasons", "number_of_episodes", "genres", "networks", "vote_average", "homepage", "poster_url", "overview"},
+		})
+	case len(args) == 2 && args[1] == "credits":
+		credits, err := client.GetTVCredits(id, &tmdb.TVCreditsRequest{Language: opts.language})
+		if err != nil {
+			return err
+		}
+		return opts.printCredits(credits, *crew)
+	case len(args) == 3 && args[1] == "season":
+		season, err := parseID(args[2])
+		if err != nil {
+			return err
+		}
+		detail, err := client.GetTVSeason(id, season, lang)
+		if err != nil {
+			return err
+		}
+		return opts.print(&result{
+			value:   detail,
+			records: detail.Episodes,
+			columns: []string{"episode_number", "name", "air_date", "vote_average"},
+		})
+	case len(args) == 5 && args[1] == "season" && args[3] == "episode":
+		season, err := parseID(args[2])
+		if err != nil {
+			return err
+		}
+		number, err := parseID(args[4])
+		if err != nil {
+			return err
+		}
+		episode, err := client.GetTVEpisode(id, season, number, lang)
+		if err != nil {
+			return err
+		}
+		return opts.print(&result{
+			value: &tvEpisode{
+				TVEpisodeDetail: episode,
+				StillURL:        client.GetImage(episode.StillPath, ""),
+			},
+			columns: []string{"id", "name", "season_number", "episode_number", "air_date", "runtime", "vote_average", "guest_stars", "still_url", "overview"},
+		})
+	}
+	return usagef("expected credits, season <n> or season <n> episode <m> after the series ID")
+}
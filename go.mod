@@ -1,3 +1,15 @@
 module github.com/song940/tmdb-go
 
 go 1.21.4
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/klauspost/compress v1.17.11
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/sync v0.10.0
+	golang.org/x/text v0.21.0
+	golang.org/x/time v0.8.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.4.0 // indirect
@@ -0,0 +1,259 @@
+// Package match identifies local media files on TMDB. Parse reads the
+// title, year and episode numbers from a filename, and File looks them up
+// with the search endpoints, returning the best match with a confidence
+// score and the runners-up. RankMovies and RankTV order search results by
+// an explained Score, and can be used on their own.
+package match
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+// ErrNoMatch is returned when a search finds no candidate at all.
+var ErrNoMatch = errors.New("match: no match")
+
+// Client is the part of tmdb.Client used for matching. persistent.Client
+// implements it as well, so lookups can be served from the cache.
+type Client interface {
+	SearchMovie(query string, opts *tmdb.SearchMovieRequest) (*tmdb.SearchMovieResponse, error)
+	SearchTV(query string, opts *tmdb.SearchTVRequest) (*tmdb.SearchTVResponse, error)
+	GetTVDetail(id int, opts *tmdb.TVDetailRequest) (*tmdb.TVDetail, error)
+	GetTVSeason(id int, season int, opts *tmdb.TVDetailRequest) (*tmdb.TVSeasonDetail, error)
+}
+
+// Options tunes File and Find.
+type Options struct {
+	Language string
+	// Alternatives is how many runners-up are returned, 4 when zero.
+	Alternatives int
+}
+
+// Candidate is a movie or a series that may be the content of a file.
+type Candidate struct {
+	Movie *tmdb.MovieObject `json:"movie,omitempty"`
+	TV    *tmdb.TVObject    `json:"tv,omitempty"`
+	// Confidence ranges from 0, unrelated, to 1, certain.
+	Confidence float64 `json:"confidence"`
+	// Score explains the confidence.
+	Score *Score `json:"score"`
+}
+
+// ID returns the TMDB ID of the movie or series.
+func (c *Candidate) ID() int {
+	if c.TV != nil {
+		return c.TV.ID
+	}
+	return c.Movie.ID
+}
+
+// Title returns the title of the movie or the name of the series.
+func (c *Candidate) Title() string {
+	if c.TV != nil {
+		return c.TV.Name
+	}
+	return c.Movie.Title
+}
+
+// Year returns the release or first air year, 0 when unknown.
+func (c *Candidate) Year() int {
+	if c.TV != nil {
+		return yearOf(c.TV.FirstAirDate)
+	}
+	return yearOf(c.Movie.ReleaseDate)
+}
+
+// Episode is an episode of the best match.
+type Episode struct {
+	ID      int    `json:"id"`
+	Season  int    `json:"season_number"`
+	Episode int    `json:"episode_number"`
+	Name    string `json:"name"`
+	AirDate string `json:"air_date"`
+}
+
+// Result is the outcome of matching a file.
+type Result struct {
+	Media *Media     `json:"media"`
+	Best  *Candidate `json:"best"`
+	// Alternatives are the next best candidates, best first.
+	Alternatives []*Candidate `json:"alternatives,omitempty"`
+	// Episodes are the episodes of the file in the best match, resolved
+	// from air dates and absolute numbers when needed. It is empty when
+	// the series has no such episodes, which lowers the confidence.
+	Episodes []Episode `json:"episodes,omitempty"`
+}
+
+// File parses filename and finds it on TMDB.
+func File(client Client, filename string, opts *Options) (*Result, error) {
+	return Find(client, Parse(filename), opts)
+}
+
+// Find looks up parsed media: episodes among series and everything else
+// among movies. When a year gives no results, it searches again without,
+// since release years often differ by one between countries.
+func Find(client Client, m *Media, opts *Options) (res *Result, err error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.Alternatives == 0 {
+		opts.Alternatives = 4
+	}
+	if m.Title == "" {
+		return nil, fmt.Errorf("%w: no title in filename", ErrNoMatch)
+	}
+	var candidates []*Candidate
+	if m.IsEpisode() {
+		candidates, err = searchTV(client, m, opts)
+	} else {
+		candidates, err = searchMovie(client, m, opts)
+	}
+	if err != nil {
+		return
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w for %q", ErrNoMatch, m.Title)
+	}
+	res = &Result{Media: m, Best: candidates[0]}
+	if rest := candidates[1:]; len(rest) > opts.Alternatives {
+		res.Alternatives = rest[:opts.Alternatives]
+	} else {
+		res.Alternatives = rest
+	}
+	if res.Best.TV != nil {
+		if res.Episodes, err = episodes(client, res.Best.TV.ID, m, opts); err != nil {
+			return nil, err
+		}
+		if len(res.Episodes) == 0 {
+			res.Best.Confidence /= 2
+			res.Best.Score.Explanation = append(res.Best.Score.Explanation, "halved: episodes not found")
+		}
+	}
+	return
+}
+
+func searchMovie(client Client, m *Media, opts *Options) (candidates []*Candidate, err error) {
+	req := &tmdb.SearchMovieRequest{Language: opts.Language}
+	if m.Year > 0 {
+		req.Year = strconv.Itoa(m.Year)
+	}
+	res, err := client.SearchMovie(m.Title, req)
+	if err == nil && len(res.Results) == 0 && req.Year != "" {
+		req.Year = ""
+		res, err = client.SearchMovie(m.Title, req)
+	}
+	if err != nil {
+		return
+	}
+	return RankMovies(Query{Title: m.Title, Year: m.Year}, res.Results), nil
+}
+
+func searchTV(client Client, m *Media, opts *Options) (candidates []*Candidate, err error) {
+	req := &tmdb.SearchTVRequest{Language: opts.Language}
+	if m.Year > 0 {
+		req.FirstAirDateYear = strconv.Itoa(m.Year)
+	}
+	res, err := client.SearchTV(m.Title, req)
+	if err == nil && len(res.Results) == 0 && req.FirstAirDateYear != "" {
+		req.FirstAirDateYear = ""
+		res, err = client.SearchTV(m.Title, req)
+	}
+	if err != nil {
+		return
+	}
+	return RankTV(Query{Title: m.Title, Year: m.Year}, res.Results), nil
+}
+
+// episodes resolves the episodes of m in a series, by season and episode
+// number, air date or absolute number.
+func episodes(client Client, id int, m *Media, opts *Options) (list []Episode, err error) {
+	lang := &tmdb.TVDetailRequest{Language: opts.Language}
+	wanted := make(map[[2]int]bool)
+	switch {
+	case len(m.Episodes) > 0:
+		for _, n := range m.Episodes {
+			wanted[[2]int{m.Season, n}] = true
+		}
+	case m.Date != "" || len(m.Absolute) > 0:
+		detail, err := client.GetTVDetail(id, lang)
+		if err != nil {
+			return nil, err
+		}
+		if m.Date != "" {
+			return byDate(client, detail, m.Date, lang)
+		}
+		// Absolute numbers count the episodes of the regular seasons in
+		// order, leaving out specials.
+		for _, n := range m.Absolute {
+			offset := 0
+			for _, season := range detail.Seasons {
+				if season.SeasonNumber == 0 {
+					continue
+				}
+				if n <= offset+season.EpisodeCount {
+					wanted[[2]int{season.SeasonNumber, n - offset}] = true
+					break
+				}
+				offset += season.EpisodeCount
+			}
+		}
+	}
+	seasons := make(map[int]bool)
+	for key := range wanted {
+		seasons[key[0]] = true
+	}
+	numbers := make([]int, 0, len(seasons))
+	for season := range seasons {
+		numbers = append(numbers, season)
+	}
+	sort.Ints(numbers)
+	for _, number := range numbers {
+		season, err := client.GetTVSeason(id, number, lang)
+		if errors.Is(err, tmdb.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, episode := range season.Episodes {
+			if wanted[[2]int{number, episode.Episode}] {
+				list = append(list, Episode{episode.ID, number, episode.Episode, episode.Name, episode.AirDate})
+			}
+		}
+	}
+	return
+}
+
+// byDate finds the episodes that aired on date, in the last season that
+// started on or before it.
+func byDate(client Client, detail *tmdb.TVDetail, date string, lang *tmdb.TVDetailRequest) (list []Episode, err error) {
+	for i := len(detail.Seasons) - 1; i >= 0; i-- {
+		season := detail.Seasons[i]
+		if season.SeasonNumber == 0 || season.AirDate == "" || season.AirDate > date {
+			continue
+		}
+		res, err := client.GetTVSeason(detail.ID, season.SeasonNumber, lang)
+		if err != nil {
+			return nil, err
+		}
+		for _, episode := range res.Episodes {
+			if episode.AirDate == date {
+				list = append(list, Episode{episode.ID, season.SeasonNumber, episode.Episode, episode.Name, episode.AirDate})
+			}
+		}
+		break
+	}
+	return
+}
+
+func yearOf(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	n, _ := strconv.Atoi(date[:4])
+	return n
+}
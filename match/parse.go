@@ -0,0 +1,155 @@
+package match
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Media is what a filename tells about its content.
+type Media struct {
+	// Title is the title as written in the filename, with separators
+	// replaced by spaces.
+	Title string `json:"title"`
+	// Year is the release or first air year, 0 when absent.
+	Year int `json:"year,omitempty"`
+	// Season and Episodes number the episodes of a series. Episodes has
+	// several numbers for multi-episode files such as S01E01E02 or
+	// S01E01-E03.
+	Season   int   `json:"season,omitempty"`
+	Episodes []int `json:"episodes,omitempty"`
+	// Date identifies an episode of a daily show, as YYYY-MM-DD.
+	Date string `json:"date,omitempty"`
+	// Absolute has the absolute episode numbers used by anime releases,
+	// such as "[Group] Title - 012 [1080p].mkv".
+	Absolute []int `json:"absolute,omitempty"`
+	// Ext is the file extension without the dot, such as "mkv".
+	Ext string `json:"ext,omitempty"`
+}
+
+// IsEpisode reports whether the file holds episodes of a series rather
+// than a movie.
+func (m *Media) IsEpisode() bool {
+	return len(m.Episodes) > 0 || m.Date != "" || len(m.Absolute) > 0
+}
+
+var (
+	// tags are bracketed release groups, checksums and qualities, except
+	// for parenthesised years which are kept.
+	tags = regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)|\{[^}]*\}`)
+	// seasonEpisode matches S01E02, S01E02E03 and S01E02-E04.
+	seasonEpisode = regexp.MustCompile(`(?i)\bS(\d{1,2}) ?E(\d{1,4})((?:E\d{1,4})*)(?:-E?(\d{1,4}))?\b`)
+	// crossEpisode matches 1x02 and 1x02-03.
+	crossEpisode = regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})(?:-(?:\d{1,2}x)?(\d{2,3}))?\b`)
+	airDate      = regexp.MustCompile(`\b((?:19|20)\d{2})[ -](\d{2})[ -](\d{2})\b`)
+	// absolute matches the " - 012" and " - 012-013" of anime releases.
+	absolute = regexp.MustCompile(`(?:^| )- (\d{1,4})(?:v\d)?(?: ?- ?(\d{1,4}))?(?: |$)`)
+	// trailingNumber matches a bare episode number ending the name of a
+	// fansub release, as in "[Group] Title 12".
+	trailingNumber = regexp.MustCompile(` (\d{2,4})(?:v\d)?$`)
+	year           = regexp.MustCompile(`\b(?:19|20)\d{2}\b`)
+	digits         = regexp.MustCompile(`\d+`)
+	// junk marks the end of the title: everything after the first
+	// quality, source or codec tag is release detail.
+	junk = regexp.MustCompile(`(?i)\b(?:2160p|1080[pi]|720p|576p|480p|4k|uhd|hdr|hdr10|bluray|blu-ray|bdrip|brrip|bdremux|remux|web-?dl|webrip|hdtv|pdtv|dvdrip|dvdscr|dvd|xvid|divx|[xh] ?26[45]|hevc|avc|aac|ac3|eac3|dts|ddp?5 1|10bit|proper|repack|extended|unrated|remastered|directors cut|multi|subbed|dubbed)\b`)
+)
+
+// Parse extracts the title, year and episode numbers from the name of a
+// media file. Directories are ignored.
+func Parse(filename string) *Media {
+	m := &Media{}
+	name := filepath.Base(filepath.ToSlash(filename))
+	if ext := filepath.Ext(name); len(ext) > 1 && len(ext) <= 5 && !isNumber(ext[1:]) {
+		m.Ext = strings.ToLower(ext[1:])
+		name = strings.TrimSuffix(name, ext)
+	}
+	fansub := strings.HasPrefix(name, "[")
+	name = tags.ReplaceAllStringFunc(name, func(tag string) string {
+		if tag[0] == '(' && year.MatchString(tag) && len(tag) == 6 {
+			return " " + tag[1:5] + " "
+		}
+		return " "
+	})
+	name = strings.Join(strings.Fields(strings.NewReplacer(".", " ", "_", " ").Replace(name)), " ")
+
+	end := len(name)
+	if loc := junk.FindStringIndex(name); loc != nil {
+		end = loc[0]
+	}
+	if match := seasonEpisode.FindStringSubmatchIndex(name); match != nil {
+		m.Season = atoi(name[match[2]:match[3]])
+		m.Episodes = []int{atoi(name[match[4]:match[5]])}
+		for _, n := range digits.FindAllString(name[match[6]:match[7]], -1) {
+			m.Episodes = append(m.Episodes, atoi(n))
+		}
+		if match[8] >= 0 {
+			m.Episodes = expand(m.Episodes, atoi(name[match[8]:match[9]]))
+		}
+		end = min(end, match[0])
+	} else if match := crossEpisode.FindStringSubmatchIndex(name); match != nil {
+		m.Season = atoi(name[match[2]:match[3]])
+		m.Episodes = []int{atoi(name[match[4]:match[5]])}
+		if match[6] >= 0 {
+			m.Episodes = expand(m.Episodes, atoi(name[match[6]:match[7]]))
+		}
+		end = min(end, match[0])
+	} else if match := airDate.FindStringSubmatchIndex(name); match != nil {
+		m.Date = name[match[2]:match[3]] + "-" + name[match[4]:match[5]] + "-" + name[match[6]:match[7]]
+		if _, err := time.Parse(time.DateOnly, m.Date); err != nil {
+			m.Date = ""
+		} else {
+			end = min(end, match[0])
+		}
+	} else if match := absolute.FindStringSubmatchIndex(name[:end]); match != nil && (fansub || !isYear(name[match[2]:match[3]])) {
+		m.Absolute = []int{atoi(name[match[2]:match[3]])}
+		if match[4] >= 0 {
+			m.Absolute = expand(m.Absolute, atoi(name[match[4]:match[5]]))
+		}
+		end = min(end, match[0])
+	} else if match := trailingNumber.FindStringSubmatchIndex(name[:end]); match != nil && fansub && !isYear(name[match[2]:match[3]]) {
+		m.Absolute = []int{atoi(name[match[2]:match[3]])}
+		end = match[0]
+	}
+
+	// The year is the last one in the title, unless it starts the title as
+	// in "1917" or "2001 A Space Odyssey".
+	title := name[:end]
+	years := year.FindAllStringIndex(title, -1)
+	for i := len(years) - 1; i >= 0; i-- {
+		if loc := years[i]; loc[0] > 0 && isYear(title[loc[0]:loc[1]]) {
+			m.Year = atoi(title[loc[0]:loc[1]])
+			title = title[:loc[0]]
+			break
+		}
+	}
+	m.Title = strings.Trim(strings.TrimSpace(title), " -")
+	return m
+}
+
+// expand turns a first and last episode into the range between them.
+func expand(episodes []int, last int) []int {
+	first := episodes[len(episodes)-1]
+	for n := first + 1; n <= last && n-first < 100; n++ {
+		episodes = append(episodes, n)
+	}
+	return episodes
+}
+
+// isYear reports whether s is a year up to next year, so that titles like
+// "Blade Runner 2049" keep their number.
+func isYear(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1900 && n <= time.Now().Year()+1
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
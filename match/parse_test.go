@@ -0,0 +1,73 @@
+package match
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		want     Media
+	}{
+		// Movies.
+		{"The.Matrix.1999.1080p.BluRay.x264-GROUP.mkv", Media{Title: "The Matrix", Year: 1999, Ext: "mkv"}},
+		{"The Matrix (1999).mp4", Media{Title: "The Matrix", Year: 1999, Ext: "mp4"}},
+		{"/movies/Inception (2010)/Inception.2010.720p.WEB-DL.mkv", Media{Title: "Inception", Year: 2010, Ext: "mkv"}},
+		{"Amelie.2001.PROPER.DVDRip.XviD.avi", Media{Title: "Amelie", Year: 2001, Ext: "avi"}},
+		{"Heat.1995.REMASTERED.2160p.UHD.HDR.mkv", Media{Title: "Heat", Year: 1995, Ext: "mkv"}},
+		{"Untitled Movie.mkv", Media{Title: "Untitled Movie", Ext: "mkv"}},
+
+		// Years inside titles.
+		{"1917.2019.1080p.mkv", Media{Title: "1917", Year: 2019, Ext: "mkv"}},
+		{"2001.A.Space.Odyssey.1968.mkv", Media{Title: "2001 A Space Odyssey", Year: 1968, Ext: "mkv"}},
+		{"Blade.Runner.2049.2017.mkv", Media{Title: "Blade Runner 2049", Year: 2017, Ext: "mkv"}},
+		{"Blade.Runner.2049.mkv", Media{Title: "Blade Runner 2049", Ext: "mkv"}},
+
+		// SxxEyy.
+		{"Game.of.Thrones.S01E01.720p.HDTV.x264.mkv", Media{Title: "Game of Thrones", Season: 1, Episodes: []int{1}, Ext: "mkv"}},
+		{"game of thrones s03e09.mkv", Media{Title: "game of thrones", Season: 3, Episodes: []int{9}, Ext: "mkv"}},
+		{"Doctor.Who.2005.S02E04.mkv", Media{Title: "Doctor Who", Year: 2005, Season: 2, Episodes: []int{4}, Ext: "mkv"}},
+
+		// 1x02.
+		{"Friends - 1x02 - The One with the Sonogram.avi", Media{Title: "Friends", Season: 1, Episodes: []int{2}, Ext: "avi"}},
+		{"friends.10x17.avi", Media{Title: "friends", Season: 10, Episodes: []int{17}, Ext: "avi"}},
+
+		// Multi-episode.
+		{"Lost.S01E01E02.mkv", Media{Title: "Lost", Season: 1, Episodes: []int{1, 2}, Ext: "mkv"}},
+		{"Lost.S01E01-E03.mkv", Media{Title: "Lost", Season: 1, Episodes: []int{1, 2, 3}, Ext: "mkv"}},
+		{"Lost.S01E01-03.mkv", Media{Title: "Lost", Season: 1, Episodes: []int{1, 2, 3}, Ext: "mkv"}},
+		{"Friends.1x01-02.avi", Media{Title: "Friends", Season: 1, Episodes: []int{1, 2}, Ext: "avi"}},
+
+		// Daily shows.
+		{"The.Daily.Show.2024.03.14.720p.mkv", Media{Title: "The Daily Show", Date: "2024-03-14", Ext: "mkv"}},
+
+		// Anime absolute numbering.
+		{"[SubsPlease] One Piece - 1071 (1080p) [ABCD1234].mkv", Media{Title: "One Piece", Absolute: []int{1071}, Ext: "mkv"}},
+		{"[Group] Naruto - 012v2 [720p].mkv", Media{Title: "Naruto", Absolute: []int{12}, Ext: "mkv"}},
+		{"[Group] Naruto - 012-013 [720p].mkv", Media{Title: "Naruto", Absolute: []int{12, 13}, Ext: "mkv"}},
+		{"[Group] Cowboy Bebop 05.mkv", Media{Title: "Cowboy Bebop", Absolute: []int{5}, Ext: "mkv"}},
+		{"Show Name - 2019.mkv", Media{Title: "Show Name", Year: 2019, Ext: "mkv"}},
+	} {
+		got := Parse(test.filename)
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.filename, *got, test.want)
+		}
+	}
+}
+
+func TestMediaIsEpisode(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		want     bool
+	}{
+		{"The.Matrix.1999.mkv", false},
+		{"Lost.S01E01.mkv", true},
+		{"The.Daily.Show.2024.03.14.mkv", true},
+		{"[Group] Naruto - 012.mkv", true},
+	} {
+		if got := Parse(test.filename).IsEpisode(); got != test.want {
+			t.Errorf("Parse(%q).IsEpisode() = %v, want %v", test.filename, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package match
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/song940/tmdb-go/tmdb"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Query is what candidates are ranked against.
+type Query struct {
+	Title string
+	// Year is the expected release or first air year, 0 when unknown.
+	Year int
+}
+
+// Weights of the factors of a Score. They add up to 1.
+const (
+	titleWeight      = 0.70
+	yearWeight       = 0.20
+	popularityWeight = 0.05
+	votesWeight      = 0.05
+)
+
+// Score explains how well a candidate matches a query. Every factor
+// ranges from 0 to 1, and Total is their weighted sum.
+type Score struct {
+	Total float64 `json:"total"`
+	// Title is the similarity of the closest of the translated and the
+	// original title, named by MatchedTitle.
+	Title        float64 `json:"title"`
+	MatchedTitle string  `json:"matched_title"`
+	Year         float64 `json:"year"`
+	// Popularity and Votes are relative to the most popular and most
+	// voted candidate, so that famous titles win ties.
+	Popularity float64 `json:"popularity"`
+	Votes      float64 `json:"votes"`
+	// Explanation has one line per factor and adjustment.
+	Explanation []string `json:"explanation"`
+}
+
+func (s *Score) String() string {
+	return fmt.Sprintf("%.3f: %s", s.Total, strings.Join(s.Explanation, "; "))
+}
+
+func (s *Score) add(name string, value, weight float64, detail string) {
+	s.Total += value * weight
+	line := fmt.Sprintf("%s %.2f × %.2f", name, value, weight)
+	if detail != "" {
+		line += " (" + detail + ")"
+	}
+	s.Explanation = append(s.Explanation, line)
+}
+
+// RankMovies scores movies against q and returns them best first. Equal
+// scores keep the order of TMDB.
+func RankMovies(q Query, movies []tmdb.MovieObject) []*Candidate {
+	var maxPopularity float32
+	maxVotes := 0
+	for _, movie := range movies {
+		maxPopularity = max(maxPopularity, movie.Popularity)
+		maxVotes = max(maxVotes, movie.VoteCount)
+	}
+	candidates := make([]*Candidate, len(movies))
+	for i := range movies {
+		movie := &movies[i]
+		score := rank(q, movie.Title, movie.OriginalTitle, movie.OriginalLanguage, yearOf(movie.ReleaseDate),
+			relative(float64(movie.Popularity), float64(maxPopularity)),
+			relative(math.Log1p(float64(movie.VoteCount)), math.Log1p(float64(maxVotes))))
+		candidates[i] = &Candidate{Movie: movie, Confidence: score.Total, Score: score}
+	}
+	sortCandidates(candidates)
+	return candidates
+}
+
+// RankTV is RankMovies for series.
+func RankTV(q Query, shows []tmdb.TVObject) []*Candidate {
+	var maxPopularity float32
+	maxVotes := 0
+	for _, tv := range shows {
+		maxPopularity = max(maxPopularity, tv.Popularity)
+		maxVotes = max(maxVotes, tv.VoteCount)
+	}
+	candidates := make([]*Candidate, len(shows))
+	for i := range shows {
+		tv := &shows[i]
+		score := rank(q, tv.Name, tv.OriginalName, tv.OriginalLanguage, yearOf(tv.FirstAirDate),
+			relative(float64(tv.Popularity), float64(maxPopularity)),
+			relative(math.Log1p(float64(tv.VoteCount)), math.Log1p(float64(maxVotes))))
+		candidates[i] = &Candidate{TV: tv, Confidence: score.Total, Score: score}
+	}
+	sortCandidates(candidates)
+	return candidates
+}
+
+func sortCandidates(candidates []*Candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+}
+
+func rank(q Query, title, original, language string, year int, popularity, votes float64) *Score {
+	score := &Score{MatchedTitle: title}
+	score.Title = similarity(q.Title, title, language)
+	detail := fmt.Sprintf("title %q", title)
+	// The original title counts slightly less, so that when both match
+	// equally the title in the requested language is reported.
+	if original != "" && original != title {
+		if sim := similarity(q.Title, original, language) * 0.98; sim > score.Title {
+			score.Title, score.MatchedTitle = sim, original
+			detail = fmt.Sprintf("original title %q", original)
+		}
+	}
+	score.add("title", score.Title, titleWeight, detail)
+
+	switch diff := q.Year - year; {
+	case q.Year == 0:
+		score.Year = 0.5
+		detail = "no year given"
+	case year == 0:
+		score.Year = 0.3
+		detail = "no release date"
+	case diff == 0:
+		score.Year = 1
+		detail = strconv.Itoa(year)
+	case diff == 1 || diff == -1:
+		score.Year = 0.6
+		detail = fmt.Sprintf("%d, off by one", year)
+	case diff == 2 || diff == -2:
+		score.Year = 0.3
+		detail = fmt.Sprintf("%d, off by two", year)
+	default:
+		detail = strconv.Itoa(year)
+	}
+	score.add("year", score.Year, yearWeight, detail)
+
+	score.Popularity, score.Votes = popularity, votes
+	score.add("popularity", popularity, popularityWeight, "")
+	score.add("votes", votes, votesWeight, "")
+	return score
+}
+
+func relative(v, max float64) float64 {
+	if max <= 0 {
+		return 0
+	}
+	return v / max
+}
+
+// Similarity compares two titles from 0, nothing in common, to 1, equal
+// once normalised. It averages the share of common words with the edit
+// distance of the whole titles, so that both reordered words and typos
+// are tolerated.
+func Similarity(a, b string) float64 {
+	return similarity(a, b, "")
+}
+
+// similarity is Similarity for titles in language, see NormalizeLanguage.
+func similarity(a, b, language string) float64 {
+	a, b = NormalizeLanguage(a, language), NormalizeLanguage(b, language)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	count := make(map[string]int)
+	for _, w := range wa {
+		count[w]++
+	}
+	shared := 0
+	for _, w := range wb {
+		if count[w] > 0 {
+			count[w]--
+			shared++
+		}
+	}
+	words := 2 * float64(shared) / float64(len(wa)+len(wb))
+	ra, rb := []rune(a), []rune(b)
+	edits := 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+	return (words + edits) / 2
+}
+
+// articles are dropped from the start and end of titles, so that "The
+// Matrix", "Matrix, The" and "Matrix" compare equal. They are listed by
+// ISO 639-1 language, as "die" is a German article but an English word.
+var articles = map[string][]string{
+	"en": {"the", "a", "an"},
+	"fr": {"le", "la", "les", "l"},
+	"es": {"el", "la", "los", "las"},
+	"de": {"der", "die", "das"},
+	"it": {"il", "lo", "la", "l", "gli"},
+}
+
+// elisions are the languages whose apostrophes separate words, as in
+// "l'amour" or "d'amore", so that elided articles can be dropped. Others
+// join the words, so that "don't" and "dont" compare equal.
+var elisions = []string{"fr", "it"}
+
+// article reports whether w is an English article or one of language.
+func article(w, language string) bool {
+	return slices.Contains(articles["en"], w) || slices.Contains(articles[language], w)
+}
+
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// Normalize reduces a title to lower case words without accents,
+// punctuation or leading and trailing English articles, with roman
+// numerals after the first word written as numbers and "&" as "and".
+// The first word is kept as is, so that "I, Robot" does not become
+// "1 robot".
+func Normalize(title string) string {
+	return NormalizeLanguage(title, "")
+}
+
+// NormalizeLanguage is Normalize for a title in language, given as an
+// ISO 639-1 code such as a TMDB original_language. The articles of
+// language are dropped along with the English ones.
+func NormalizeLanguage(title, language string) string {
+	if s, _, err := transform.String(stripMarks, title); err == nil {
+		title = s
+	}
+	apostrophe := ""
+	if slices.Contains(elisions, language) {
+		apostrophe = " "
+	}
+	title = strings.NewReplacer("&", " and ", "'", apostrophe, "’", apostrophe).Replace(strings.ToLower(title))
+	words := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && article(words[0], language) {
+		words = words[1:]
+	}
+	for len(words) > 1 && article(words[len(words)-1], language) {
+		words = words[:len(words)-1]
+	}
+	for i, w := range words {
+		if n := roman(w); n > 0 && i > 0 {
+			words[i] = strconv.Itoa(n)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+var romanDigits = map[byte]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100}
+
+// roman returns the value of a lower case roman numeral up to 399, or 0
+// when s is not one.
+func roman(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		v, ok := romanDigits[s[i]]
+		if !ok {
+			return 0
+		}
+		if i+1 < len(s) && romanDigits[s[i+1]] > v {
+			n -= v
+		} else {
+			n += v
+		}
+	}
+	// Round-tripping rejects words such as "civil" or "mix" that only
+	// happen to use the same letters.
+	if n <= 0 || n >= 400 || toRoman(n) != s {
+		return 0
+	}
+	return n
+}
+
+func toRoman(n int) string {
+	var b strings.Builder
+	for _, r := range []struct {
+		value  int
+		symbol string
+	}{{100, "c"}, {90, "xc"}, {50, "l"}, {40, "xl"}, {10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"}} {
+		for n >= r.value {
+			b.WriteString(r.symbol)
+			n -= r.value
+		}
+	}
+	return b.String()
+}
+
+func levenshtein(a, b []rune) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			prev, row[j] = row[j], min(row[j]+1, row[j-1]+1, prev+cost)
+		}
+	}
+	return row[len(b)]
+}
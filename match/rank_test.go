@@ -0,0 +1,161 @@
+package match
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	for _, test := range []struct {
+		title, language, want string
+	}{
+		{"The Matrix", "en", "matrix"},
+		{"Matrix, The", "", "matrix"},
+		{"Die Hard", "en", "die hard"},
+		{"Das Boot", "de", "boot"},
+		{"Le Fabuleux Destin d'Amélie Poulain", "fr", "fabuleux destin d amelie poulain"},
+		{"L'Auberge espagnole", "fr", "auberge espagnole"},
+		{"L’Avventura", "it", "avventura"},
+		{"L'Avventura", "en", "lavventura"},
+		{"Don't Look Up", "en", "dont look up"},
+		{"I, Robot", "en", "i robot"},
+		{"Rocky II", "en", "rocky 2"},
+		{"Star Wars: Episode IV - A New Hope", "en", "star wars episode 4 a new hope"},
+		{"Fast & Furious", "en", "fast and furious"},
+	} {
+		if got := NormalizeLanguage(test.title, test.language); got != test.want {
+			t.Errorf("NormalizeLanguage(%q, %q) = %q, want %q", test.title, test.language, got, test.want)
+		}
+	}
+}
+
+func TestSimilarityKeepsForeignArticles(t *testing.T) {
+	if sim := Similarity("Die Hard", "Hard"); sim >= 1 {
+		t.Errorf(`Similarity("Die Hard", "Hard") = %v, want below 1`, sim)
+	}
+	if sim := similarity("Das Boot", "Boot", "de"); sim != 1 {
+		t.Errorf(`similarity("Das Boot", "Boot", "de") = %v, want 1`, sim)
+	}
+}
+
+// ids returns the IDs of candidates in order.
+func ids(candidates []*Candidate) (ids []int) {
+	for _, c := range candidates {
+		ids = append(ids, c.ID())
+	}
+	return
+}
+
+func TestRankMovies(t *testing.T) {
+	amelie := tmdb.MovieObject{ID: 194, Title: "Amélie", OriginalTitle: "Le Fabuleux Destin d'Amélie Poulain", OriginalLanguage: "fr", ReleaseDate: "2001-04-25", Popularity: 30, VoteCount: 10000}
+	montmartre := tmdb.MovieObject{ID: 1, Title: "Amélie de Montmartre", OriginalLanguage: "fr", ReleaseDate: "2001-01-01", Popularity: 1, VoteCount: 3}
+	for _, test := range []struct {
+		name   string
+		query  Query
+		movies []tmdb.MovieObject
+		want   []int
+		// explanation is expected in the score of the first candidate.
+		explanation []string
+	}{
+		{
+			"translated title",
+			Query{Title: "Amelie", Year: 2001},
+			[]tmdb.MovieObject{montmartre, amelie},
+			[]int{194, 1},
+			[]string{`title 1.00 × 0.70 (title "Amélie")`, "year 1.00 × 0.20 (2001)"},
+		},
+		{
+			"original title",
+			Query{Title: "Le Fabuleux Destin d Amelie Poulain", Year: 2001},
+			[]tmdb.MovieObject{montmartre, amelie},
+			[]int{194, 1},
+			[]string{`title 0.98 × 0.70 (original title "Le Fabuleux Destin d'Amélie Poulain")`},
+		},
+		{
+			"year proximity",
+			Query{Title: "Dune", Year: 2021},
+			[]tmdb.MovieObject{
+				{ID: 841, Title: "Dune", ReleaseDate: "1984-12-14", Popularity: 1, VoteCount: 1},
+				{ID: 2, Title: "Dune", ReleaseDate: "2019-01-01", Popularity: 1, VoteCount: 1},
+				{ID: 3, Title: "Dune", ReleaseDate: "2020-01-01", Popularity: 1, VoteCount: 1},
+				{ID: 438631, Title: "Dune", ReleaseDate: "2021-09-15", Popularity: 40, VoteCount: 9000},
+			},
+			[]int{438631, 3, 2, 841},
+			[]string{"year 1.00 × 0.20 (2021)", "popularity 1.00 × 0.05", "votes 1.00 × 0.05"},
+		},
+		{
+			"popularity",
+			Query{Title: "Heat"},
+			[]tmdb.MovieObject{
+				{ID: 1, Title: "Heat", ReleaseDate: "1986-03-14", Popularity: 5, VoteCount: 100},
+				{ID: 949, Title: "Heat", ReleaseDate: "1995-12-15", Popularity: 60, VoteCount: 7000},
+			},
+			[]int{949, 1},
+			[]string{"year 0.50 × 0.20 (no year given)", "popularity 1.00 × 0.05"},
+		},
+		{
+			"ties keep the order of TMDB",
+			Query{Title: "Solaris", Year: 1972},
+			[]tmdb.MovieObject{
+				{ID: 2, Title: "Solaris", ReleaseDate: "1972-03-20"},
+				{ID: 1, Title: "Solaris", ReleaseDate: "1972-03-20"},
+			},
+			[]int{2, 1},
+			[]string{"popularity 0.00 × 0.05"},
+		},
+	} {
+		candidates := RankMovies(test.query, test.movies)
+		if got := ids(candidates); fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: ranked %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := 1; i < len(candidates); i++ {
+			if candidates[i].Confidence > candidates[i-1].Confidence {
+				t.Errorf("%s: candidate %d more confident than the one before", test.name, i)
+			}
+		}
+		best := candidates[0].Score
+		for _, line := range test.explanation {
+			if !slices.Contains(best.Explanation, line) {
+				t.Errorf("%s: explanation %q lacks %q", test.name, best.Explanation, line)
+			}
+		}
+	}
+}
+
+func TestRankTV(t *testing.T) {
+	moneyHeist := tmdb.TVObject{ID: 71446, Name: "Money Heist", OriginalName: "La casa de papel", OriginalLanguage: "es", FirstAirDate: "2017-05-02", Popularity: 80, VoteCount: 18000}
+	officeUK := tmdb.TVObject{ID: 2996, Name: "The Office", OriginalLanguage: "en", FirstAirDate: "2001-07-09", Popularity: 30, VoteCount: 2000}
+	officeUS := tmdb.TVObject{ID: 2316, Name: "The Office", OriginalLanguage: "en", FirstAirDate: "2005-03-24", Popularity: 200, VoteCount: 4000}
+	for _, test := range []struct {
+		name        string
+		query       Query
+		shows       []tmdb.TVObject
+		want        []int
+		matched     string
+		explanation string
+	}{
+		{"original name", Query{Title: "La Casa de Papel"}, []tmdb.TVObject{officeUK, moneyHeist}, []int{71446, 2996}, "La casa de papel", `original title "La casa de papel"`},
+		{"translated name", Query{Title: "Money Heist"}, []tmdb.TVObject{officeUK, moneyHeist}, []int{71446, 2996}, "Money Heist", `title "Money Heist"`},
+		{"year over popularity", Query{Title: "The Office", Year: 2001}, []tmdb.TVObject{officeUS, officeUK}, []int{2996, 2316}, "The Office", "year 1.00 × 0.20 (2001)"},
+		{"off by one", Query{Title: "The Office", Year: 2004}, []tmdb.TVObject{officeUK, officeUS}, []int{2316, 2996}, "The Office", "(2005, off by one)"},
+		{"popularity", Query{Title: "Office"}, []tmdb.TVObject{officeUK, officeUS}, []int{2316, 2996}, "The Office", "popularity 1.00 × 0.05"},
+	} {
+		candidates := RankTV(test.query, test.shows)
+		if got := ids(candidates); fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: ranked %v, want %v", test.name, got, test.want)
+			continue
+		}
+		best := candidates[0]
+		if best.TV == nil || best.Score.MatchedTitle != test.matched {
+			t.Errorf("%s: matched %q, want %q", test.name, best.Score.MatchedTitle, test.matched)
+		}
+		if explanation := best.Score.String(); !strings.Contains(explanation, test.explanation) {
+			t.Errorf("%s: explanation %q lacks %q", test.name, explanation, test.explanation)
+		}
+	}
+}
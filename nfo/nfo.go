@@ -0,0 +1,369 @@
+// Package nfo writes the NFO files read by Kodi, Jellyfin and Emby:
+// movie.nfo, tvshow.nfo and one .nfo per episode, from TMDB details.
+//
+// https://kodi.wiki/view/NFO_files
+package nfo
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+const header = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// Images resolves artwork paths to URLs. tmdb.Client and
+// persistent.Client implement it with GetImage.
+type Images interface {
+	GetImage(path string, size string) string
+}
+
+type Ratings struct {
+	Ratings []Rating `xml:"rating"`
+}
+
+type Rating struct {
+	Name    string  `xml:"name,attr"`
+	Max     int     `xml:"max,attr"`
+	Default bool    `xml:"default,attr"`
+	Value   float32 `xml:"value"`
+	Votes   int     `xml:"votes"`
+}
+
+type UniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	ID      string `xml:",chardata"`
+}
+
+// Thumb is a piece of artwork. Aspect is "poster", "banner", "clearlogo"
+// and so on, Season is set for season artwork.
+type Thumb struct {
+	Aspect  string `xml:"aspect,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Season  *int   `xml:"season,attr"`
+	Preview string `xml:"preview,attr,omitempty"`
+	URL     string `xml:",chardata"`
+}
+
+type Fanart struct {
+	Thumbs []Thumb `xml:"thumb"`
+}
+
+type Actor struct {
+	Name  string `xml:"name"`
+	Role  string `xml:"role,omitempty"`
+	Order int    `xml:"order"`
+	Thumb string `xml:"thumb,omitempty"`
+	TMDB  int    `xml:"tmdbid,omitempty"`
+}
+
+type Set struct {
+	Name     string `xml:"name"`
+	Overview string `xml:"overview,omitempty"`
+}
+
+type NamedSeason struct {
+	Number int    `xml:"number,attr"`
+	Name   string `xml:",chardata"`
+}
+
+// Movie is the content of movie.nfo.
+type Movie struct {
+	XMLName       xml.Name   `xml:"movie"`
+	Title         string     `xml:"title"`
+	OriginalTitle string     `xml:"originaltitle,omitempty"`
+	Ratings       *Ratings   `xml:"ratings"`
+	Plot          string     `xml:"plot,omitempty"`
+	Tagline       string     `xml:"tagline,omitempty"`
+	Runtime       int        `xml:"runtime,omitempty"`
+	Thumbs        []Thumb    `xml:"thumb"`
+	Fanart        *Fanart    `xml:"fanart"`
+	UniqueIDs     []UniqueID `xml:"uniqueid"`
+	Genres        []string   `xml:"genre"`
+	Countries     []string   `xml:"country"`
+	Set           *Set       `xml:"set"`
+	Credits       []string   `xml:"credits"`
+	Directors     []string   `xml:"director"`
+	Premiered     string     `xml:"premiered,omitempty"`
+	Year          int        `xml:"year,omitempty"`
+	Status        string     `xml:"status,omitempty"`
+	Studios       []string   `xml:"studio"`
+	Actors        []Actor    `xml:"actor"`
+}
+
+// TVShow is the content of tvshow.nfo.
+type TVShow struct {
+	XMLName       xml.Name      `xml:"tvshow"`
+	Title         string        `xml:"title"`
+	OriginalTitle string        `xml:"originaltitle,omitempty"`
+	Ratings       *Ratings      `xml:"ratings"`
+	Plot          string        `xml:"plot,omitempty"`
+	Tagline       string        `xml:"tagline,omitempty"`
+	Runtime       int           `xml:"runtime,omitempty"`
+	Thumbs        []Thumb       `xml:"thumb"`
+	Fanart        *Fanart       `xml:"fanart"`
+	UniqueIDs     []UniqueID    `xml:"uniqueid"`
+	Genres        []string      `xml:"genre"`
+	Premiered     string        `xml:"premiered,omitempty"`
+	Year          int           `xml:"year,omitempty"`
+	Status        string        `xml:"status,omitempty"`
+	Studios       []string      `xml:"studio"`
+	Credits       []string      `xml:"credits"`
+	Seasons       []NamedSeason `xml:"namedseason"`
+	Actors        []Actor       `xml:"actor"`
+}
+
+// Episode is the content of the .nfo next to an episode file.
+type Episode struct {
+	XMLName   xml.Name   `xml:"episodedetails"`
+	Title     string     `xml:"title"`
+	ShowTitle string     `xml:"showtitle,omitempty"`
+	Season    int        `xml:"season"`
+	Episode   int        `xml:"episode"`
+	Ratings   *Ratings   `xml:"ratings"`
+	Plot      string     `xml:"plot,omitempty"`
+	Runtime   int        `xml:"runtime,omitempty"`
+	Thumbs    []Thumb    `xml:"thumb"`
+	UniqueIDs []UniqueID `xml:"uniqueid"`
+	Credits   []string   `xml:"credits"`
+	Directors []string   `xml:"director"`
+	Aired     string     `xml:"aired,omitempty"`
+	Studios   []string   `xml:"studio"`
+	Actors    []Actor    `xml:"actor"`
+}
+
+// NewMovie maps a movie and, when not nil, its credits.
+func NewMovie(images Images, detail *tmdb.MovieDetail, credits *tmdb.MovieCredits) *Movie {
+	m := &Movie{
+		Title:         detail.Title,
+		OriginalTitle: detail.OriginalTitle,
+		Ratings:       ratings(detail.VoteAverage, detail.VoteCount),
+		Plot:          detail.Overview,
+		Tagline:       detail.Tagline,
+		Runtime:       detail.Runtime,
+		Thumbs:        posters(images, detail.PosterPath),
+		Fanart:        fanart(images, detail.BackdropPath),
+		UniqueIDs:     []UniqueID{{Type: "tmdb", Default: true, ID: strconv.Itoa(detail.ID)}},
+		Premiered:     detail.ReleaseDate,
+		Year:          yearOf(detail.ReleaseDate),
+		Status:        detail.Status,
+	}
+	if detail.IMDbID != "" {
+		m.UniqueIDs = append(m.UniqueIDs, UniqueID{Type: "imdb", ID: detail.IMDbID})
+	}
+	for _, genre := range detail.Genres {
+		m.Genres = append(m.Genres, genre.Name)
+	}
+	for _, country := range detail.ProductionCountries {
+		m.Countries = append(m.Countries, country.Name)
+	}
+	for _, company := range detail.ProductionCompanies {
+		m.Studios = append(m.Studios, company.Name)
+	}
+	if collection := detail.BelongsToCollection; collection.Name != "" {
+		m.Set = &Set{Name: collection.Name}
+	}
+	if credits != nil {
+		m.Actors = actors(images, credits.Cast, nil)
+		m.Directors, m.Credits = crew(credits.Crew)
+	}
+	return m
+}
+
+// NewTVShow maps a series and, when not nil, its credits.
+func NewTVShow(images Images, detail *tmdb.TVDetail, credits *tmdb.MovieCredits) *TVShow {
+	s := &TVShow{
+		Title:         detail.Name,
+		OriginalTitle: detail.OriginalName,
+		Ratings:       ratings(detail.VoteAverage, detail.VoteCount),
+		Plot:          detail.Overview,
+		Tagline:       detail.Tagline,
+		Thumbs:        posters(images, detail.PosterPath),
+		Fanart:        fanart(images, detail.BackdropPath),
+		UniqueIDs:     []UniqueID{{Type: "tmdb", Default: true, ID: strconv.Itoa(detail.ID)}},
+		Premiered:     detail.FirstAirDate,
+		Year:          yearOf(detail.FirstAirDate),
+		Status:        detail.Status,
+	}
+	if len(detail.EpisodeRunTime) > 0 {
+		s.Runtime = detail.EpisodeRunTime[0]
+	}
+	for _, genre := range detail.Genres {
+		s.Genres = append(s.Genres, genre.Name)
+	}
+	for _, network := range detail.Networks {
+		s.Studios = append(s.Studios, network.Name)
+	}
+	for _, creator := range detail.CreatedBy {
+		s.Credits = append(s.Credits, creator.Name)
+	}
+	for _, season := range detail.Seasons {
+		number := season.SeasonNumber
+		s.Seasons = append(s.Seasons, NamedSeason{Number: number, Name: season.Name})
+		if season.PosterPath != "" {
+			s.Thumbs = append(s.Thumbs, Thumb{
+				Aspect:  "poster",
+				Type:    "season",
+				Season:  &number,
+				Preview: images.GetImage(season.PosterPath, previewSize),
+				URL:     images.GetImage(season.PosterPath, ""),
+			})
+		}
+	}
+	if credits != nil {
+		s.Actors = actors(images, credits.Cast, nil)
+	}
+	return s
+}
+
+// NewEpisodes maps every episode of a season. Guest stars follow the cast
+// of the series, when show credits are given.
+func NewEpisodes(images Images, show *tmdb.TVDetail, season *tmdb.TVSeasonDetail, credits *tmdb.MovieCredits) []*Episode {
+	list := make([]*Episode, 0, len(season.Episodes))
+	for _, episode := range season.Episodes {
+		e := &Episode{
+			Title:     episode.Name,
+			ShowTitle: show.Name,
+			Season:    episode.Season,
+			Episode:   episode.Episode,
+			Ratings:   ratings(episode.VoteAverage, episode.VoteCount),
+			Plot:      episode.Overview,
+			UniqueIDs: []UniqueID{{Type: "tmdb", Default: true, ID: strconv.Itoa(episode.ID)}},
+			Aired:     episode.AirDate,
+		}
+		if episode.StillPath != "" {
+			e.Thumbs = []Thumb{{URL: images.GetImage(episode.StillPath, "")}}
+		}
+		for _, network := range show.Networks {
+			e.Studios = append(e.Studios, network.Name)
+		}
+		e.Directors, e.Credits = crew(episode.Crew)
+		var cast []tmdb.CastMember
+		if credits != nil {
+			cast = credits.Cast
+		}
+		e.Actors = actors(images, cast, episode.GuestStars)
+		list = append(list, e)
+	}
+	return list
+}
+
+// NewEpisode maps a single episode fetched with GetTVEpisode.
+func NewEpisode(images Images, show *tmdb.TVDetail, episode *tmdb.TVEpisodeDetail) *Episode {
+	e := &Episode{
+		Title:     episode.Name,
+		ShowTitle: show.Name,
+		Season:    episode.Season,
+		Episode:   episode.Episode,
+		Ratings:   ratings(episode.VoteAverage, episode.VoteCount),
+		Plot:      episode.Overview,
+		Runtime:   episode.Runtime,
+		UniqueIDs: []UniqueID{{Type: "tmdb", Default: true, ID: strconv.Itoa(episode.ID)}},
+		Aired:     episode.AirDate,
+	}
+	if episode.StillPath != "" {
+		e.Thumbs = []Thumb{{URL: images.GetImage(episode.StillPath, "")}}
+	}
+	for _, network := range show.Networks {
+		e.Studios = append(e.Studios, network.Name)
+	}
+	e.Directors, e.Credits = crew(episode.Crew)
+	e.Actors = actors(images, nil, episode.GuestStars)
+	return e
+}
+
+// Encode writes v, one of *Movie, *TVShow or *Episode, as an indented
+// XML document.
+func Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteFile encodes v into filename.
+func WriteFile(filename string, v any) error {
+	var b strings.Builder
+	if err := Encode(&b, v); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
+
+// previewSize is the TMDB image size of artwork previews.
+const previewSize = "w500"
+
+func ratings(average float32, votes int) *Ratings {
+	if votes == 0 {
+		return nil
+	}
+	return &Ratings{Ratings: []Rating{{Name: "themoviedb", Max: 10, Default: true, Value: average, Votes: votes}}}
+}
+
+func posters(images Images, path string) []Thumb {
+	if path == "" {
+		return nil
+	}
+	return []Thumb{{Aspect: "poster", Preview: images.GetImage(path, previewSize), URL: images.GetImage(path, "")}}
+}
+
+func fanart(images Images, path string) *Fanart {
+	if path == "" {
+		return nil
+	}
+	return &Fanart{Thumbs: []Thumb{{Preview: images.GetImage(path, previewSize), URL: images.GetImage(path, "")}}}
+}
+
+// actors lists the cast, then the guest stars, in billing order.
+func actors(images Images, cast []tmdb.CastMember, guests []tmdb.GuestStar) (list []Actor) {
+	add := func(member tmdb.Member) {
+		actor := Actor{Name: member.Name, Role: member.Character, Order: len(list), TMDB: member.ID}
+		if member.ProfilePath != "" {
+			actor.Thumb = images.GetImage(member.ProfilePath, "")
+		}
+		list = append(list, actor)
+	}
+	for _, member := range cast {
+		add(member.Member)
+	}
+	for _, guest := range guests {
+		add(guest.Member)
+	}
+	return
+}
+
+// crew returns the directors and the writers, who Kodi lists as credits.
+// People with several writing jobs are listed once.
+func crew(members []tmdb.CrewMember) (directors, writers []string) {
+	seen := make(map[string]bool)
+	for _, member := range members {
+		switch {
+		case member.Job == "Director" && !seen["director "+member.Name]:
+			seen["director "+member.Name] = true
+			directors = append(directors, member.Name)
+		case member.Department == "Writing" && !seen["writer "+member.Name]:
+			seen["writer "+member.Name] = true
+			writers = append(writers, member.Name)
+		}
+	}
+	return
+}
+
+func yearOf(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	n, _ := strconv.Atoi(date[:4])
+	return n
+}
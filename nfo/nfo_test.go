@@ -0,0 +1,133 @@
+package nfo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/song940/tmdb-go/tmdbtest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// images resolves artwork like TMDB, independently of the test server.
+type images struct{}
+
+func (images) GetImage(path, size string) string {
+	if size == "" {
+		size = "original"
+	}
+	return "https://image.tmdb.org/t/p/" + size + path
+}
+
+func TestEncodeGolden(t *testing.T) {
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	movie, err := client.GetMovieDetail(tmdbtest.MovieID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movieCredits, err := client.GetMovieCredits(tmdbtest.MovieID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	show, err := client.GetTVDetail(tmdbtest.TVID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	showCredits, err := client.GetTVCredits(tmdbtest.TVID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	episode, err := client.GetTVEpisode(tmdbtest.TVID, tmdbtest.SeasonNumber, tmdbtest.EpisodeNumber, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	season, err := client.GetTVSeason(tmdbtest.TVID, tmdbtest.SeasonNumber, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	episodes := NewEpisodes(images{}, show, season, showCredits)
+	if len(episodes) != 2 {
+		t.Fatalf("NewEpisodes mapped %d episodes, want 2", len(episodes))
+	}
+
+	for _, test := range []struct {
+		name string
+		v    any
+	}{
+		{"movie", NewMovie(images{}, movie, movieCredits)},
+		{"tvshow", NewTVShow(images{}, show, showCredits)},
+		{"episode", NewEpisode(images{}, show, episode)},
+		{"season-1x01", episodes[0]},
+		{"season-1x02", episodes[1]},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, test.v); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", test.name+".nfo")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("%s differs from %s:\n%s", test.name, golden, got)
+			}
+		})
+	}
+}
+
+// TestKodiSample checks the element names and attributes of movie.nfo
+// against a sample in the format Kodi documents, independently of the
+// goldens: both must decode to the same values.
+func TestKodiSample(t *testing.T) {
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	detail, err := client.GetMovieDetail(tmdbtest.MovieID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credits, err := client.GetMovieCredits(tmdbtest.MovieID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, NewMovie(images{}, detail, credits)); err != nil {
+		t.Fatal(err)
+	}
+	var got, want Movie
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	sample, err := os.ReadFile(filepath.Join("testdata", "kodi", "movie.nfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(sample, &want); err != nil {
+		t.Fatal(err)
+	}
+	// Kodi has no person IDs.
+	for i := range got.Actors {
+		got.Actors[i].TMDB = 0
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("movie.nfo decodes to\n%+v\nthe Kodi sample to\n%+v", got, want)
+	}
+	if len(got.Actors) == 0 || len(got.Fanart.Thumbs) == 0 || got.Ratings == nil {
+		t.Errorf("decoded %+v, missing nested elements", got)
+	}
+}
@@ -0,0 +1,150 @@
+package persistent
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+const (
+	archiveVersion  = 1
+	manifestName    = "manifest.json"
+	archiveEntryDir = "entries/"
+)
+
+// Manifest is the first file of an exported archive. It lists every entry
+// with the time it was fetched and the request it answers.
+type Manifest struct {
+	Version  int         `json:"version"`
+	Exported time.Time   `json:"exported"`
+	Entries  []*Metadata `json:"entries"`
+}
+
+// Export writes every entry of store to w as a gzipped tar archive: a
+// manifest.json followed by one file per entry under entries/. Entries are
+// read with Peek when the store has it, so their access times are kept.
+func Export(store Store, w io.Writer) (n int, err error) {
+	list, err := store.List("")
+	if err != nil {
+		return
+	}
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	manifest, err := json.MarshalIndent(&Manifest{
+		Version:  archiveVersion,
+		Exported: time.Now(),
+		Entries:  list,
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+	if err = writeArchiveFile(tw, manifestName, manifest, time.Now()); err != nil {
+		return
+	}
+	for _, meta := range list {
+		data, _, e := peek(store, meta.Key)
+		if errors.Is(e, ErrNotFound) || errors.Is(e, ErrCorrupt) {
+			// Removed or damaged since it was listed, Import skips it.
+			continue
+		}
+		if e != nil {
+			return n, e
+		}
+		if err = writeArchiveFile(tw, archiveEntryDir+meta.Key, data, meta.ModTime); err != nil {
+			return
+		}
+		n++
+	}
+	if err = tw.Close(); err != nil {
+		return
+	}
+	err = zw.Close()
+	return
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// Import merges an archive written by Export into store. An entry replaces
+// an existing one only if it was fetched more recently, so the newest copy
+// always wins. It reports how many entries were written.
+func Import(store Store, r io.Reader) (n int, err error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	hdr, err := tr.Next()
+	if err != nil {
+		return
+	}
+	if hdr.Name != manifestName {
+		return 0, fmt.Errorf("persistent: archive does not start with %s", manifestName)
+	}
+	var manifest Manifest
+	if err = json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return
+	}
+	if manifest.Version > archiveVersion {
+		return 0, fmt.Errorf("persistent: unsupported archive version %d", manifest.Version)
+	}
+	entries := make(map[string]*Metadata, len(manifest.Entries))
+	for _, meta := range manifest.Entries {
+		entries[meta.Key] = meta
+	}
+	for {
+		hdr, err = tr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return
+		}
+		key := path.Base(hdr.Name)
+		meta, ok := entries[key]
+		if !ok || path.Dir(hdr.Name)+"/" != archiveEntryDir {
+			continue
+		}
+		data, e := io.ReadAll(tr)
+		if e != nil {
+			return n, e
+		}
+		if meta.Checksum != "" && checksum(data) != meta.Checksum {
+			return n, fmt.Errorf("%w: %s in archive", ErrCorrupt, key)
+		}
+		if _, existing, e := peek(store, key); e == nil && !existing.ModTime.Before(meta.ModTime) {
+			continue
+		}
+		if err = store.Set(key, data, meta); err != nil {
+			return
+		}
+		n++
+	}
+}
+
+// Export writes the cache to w, see Export.
+func (client *Client) Export(w io.Writer) (int, error) {
+	return Export(client.Store, w)
+}
+
+// Import merges an archive into the cache, see Import.
+func (client *Client) Import(r io.Reader) (int, error) {
+	return Import(client.Store, r)
+}
@@ -0,0 +1,111 @@
+package persistent
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExportKeepsAccessTimes(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("movie-%d.0123456789abcdef.json", i)
+		if err := store.Set(key, []byte(`{"id":1}`), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(store.flat(key), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	n, err := Export(store, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("exported %d entries, want 3", n)
+	}
+	list, err := store.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, meta := range list {
+		if !meta.AccessTime.Equal(old) {
+			t.Errorf("%s: access time %v, want %v", meta.Key, meta.AccessTime, old)
+		}
+	}
+
+	imported := NewMemoryStore(0)
+	if n, err := Import(imported, &buf); err != nil || n != 3 {
+		t.Fatalf("imported %d entries, %v, want 3", n, err)
+	}
+}
+
+func TestImportKeepsNewest(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	older, newer := now.Add(-48*time.Hour), now.Add(-time.Hour)
+	exported, local := NewMemoryStore(0), NewMemoryStore(0)
+	for _, entry := range []struct {
+		store   Store
+		key     string
+		modTime time.Time
+		title   string
+	}{
+		// Newer in the archive.
+		{exported, "movie-1.0123456789abcdef.json", newer, "archive"},
+		{local, "movie-1.0123456789abcdef.json", older, "local"},
+		// Newer locally.
+		{exported, "movie-2.0123456789abcdef.json", older, "archive"},
+		{local, "movie-2.0123456789abcdef.json", newer, "local"},
+		// Fetched at the same time.
+		{exported, "movie-3.0123456789abcdef.json", newer, "archive"},
+		{local, "movie-3.0123456789abcdef.json", newer, "local"},
+		// On one side only.
+		{exported, "movie-4.0123456789abcdef.json", older, "archive"},
+		{local, "movie-5.0123456789abcdef.json", older, "local"},
+	} {
+		data := []byte(fmt.Sprintf(`{"title":%q}`, entry.title))
+		meta := &Metadata{ModTime: entry.modTime, Request: "/movie/1?"}
+		if err := entry.store.Set(entry.key, data, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := Export(exported, &buf); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Import(local, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("imported %d entries, want 2", n)
+	}
+	for key, want := range map[string]string{
+		"movie-1.0123456789abcdef.json": "archive",
+		"movie-2.0123456789abcdef.json": "local",
+		"movie-3.0123456789abcdef.json": "local",
+		"movie-4.0123456789abcdef.json": "archive",
+		"movie-5.0123456789abcdef.json": "local",
+	} {
+		data, meta, err := local.Get(key)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if got := fmt.Sprintf(`{"title":%q}`, want); string(data) != got {
+			t.Errorf("%s holds %s, want the %s copy", key, data, want)
+		}
+		if meta.Request != "/movie/1?" {
+			t.Errorf("%s: imported request %q", key, meta.Request)
+		}
+	}
+	if _, meta, _ := local.Get("movie-1.0123456789abcdef.json"); !meta.ModTime.Equal(newer) {
+		t.Errorf("imported entry fetched at %v, want %v", meta.ModTime, newer)
+	}
+}
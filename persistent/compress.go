@@ -0,0 +1,93 @@
+package persistent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects how a FileStore compresses entries on disk.
+type Compression string
+
+const (
+	NoCompression Compression = ""
+	Gzip          Compression = "gzip"
+	Zstd          Compression = "zstd"
+)
+
+// ParseCompression returns the Compression named s: none or the empty
+// string, gzip or zstd.
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(strings.ToLower(strings.TrimSpace(s))); c {
+	case NoCompression, Gzip, Zstd:
+		return c, nil
+	case "none":
+		return NoCompression, nil
+	}
+	return NoCompression, fmt.Errorf("persistent: unknown compression %q, expected none, gzip or zstd", s)
+}
+
+// UnmarshalText parses a compression name, so that config files are
+// checked when they are loaded.
+func (c *Compression) UnmarshalText(text []byte) (err error) {
+	*c, err = ParseCompression(string(text))
+	return
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder) {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil)
+		zstdDecoder, _ = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder
+}
+
+func compress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		encoder, _ := zstdCodec()
+		return encoder.EncodeAll(data, nil), nil
+	}
+	return nil, fmt.Errorf("persistent: unknown compression %q", c)
+}
+
+func decompress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case Zstd:
+		_, decoder := zstdCodec()
+		return decoder.DecodeAll(data, nil)
+	}
+	return nil, fmt.Errorf("persistent: unknown compression %q", c)
+}
@@ -0,0 +1,206 @@
+package persistent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFiles are looked for, in order, in the default directory.
+var configFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// LoadOptions tunes LoadConfig.
+type LoadOptions struct {
+	// Path is the config file, YAML unless it ends in .toml. When empty,
+	// $TMDB_CONFIG is used, or else config.yaml, config.yml or config.toml
+	// in the tmdb user config directory, which may be missing.
+	Path string
+	// Profile selects a section of the profiles table, which overrides
+	// the top level settings. When empty, $TMDB_PROFILE is used, or else
+	// the profile key of the file.
+	Profile string
+	// Getenv looks up environment variables, os.Getenv when nil.
+	Getenv func(string) string
+}
+
+// LoadConfig reads a Config from a config file and the environment. Later
+// sources take precedence: the top level of the file, then the selected
+// profile, then these environment variables:
+//
+//	TMDB_API, TMDB_API_KEY, TMDB_ACCESS_TOKEN, TMDB_IMAGE_URL
+//	TMDB_CACHE_DIR, TMDB_CACHE_POLICY, TMDB_CACHE_MAX_AGE,
+//	TMDB_CACHE_MAX_SIZE, TMDB_CACHE_COMPRESSION
+//
+// Command-line flags, applied by the caller to the returned Config, come
+// last. A file looks like:
+//
+//	api_key: ...
+//	max_age: 720h
+//	profile: production
+//	profiles:
+//	  staging:
+//	    api: https://tmdb-proxy.staging.example.com/3
+//	    policy: network-only
+//	  production:
+//	    compression: zstd
+func LoadConfig(opts *LoadOptions) (config *Config, err error) {
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	path := opts.Path
+	if path == "" {
+		path = getenv("TMDB_CONFIG")
+	}
+	profile := opts.Profile
+	if profile == "" {
+		profile = getenv("TMDB_PROFILE")
+	}
+	config = &Config{}
+	if path == "" {
+		path = defaultConfigFile()
+	}
+	if path != "" {
+		if err = loadFile(config, path, profile); err != nil {
+			return nil, err
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("persistent: profile %q selected without a config file", profile)
+	}
+	if err = loadEnv(config, getenv); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// defaultConfigFile returns the first config file found in the default
+// directory, or "" if there is none.
+func defaultConfigFile() string {
+	for _, name := range configFiles {
+		filename := filepath.Join(defaultDir(), name)
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+	}
+	return ""
+}
+
+func loadFile(config *Config, path, profile string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".toml" {
+		err = decodeTOML(config, data, profile)
+	} else {
+		err = decodeYAML(config, data, profile)
+	}
+	if err != nil {
+		return fmt.Errorf("persistent: %s: %w", path, err)
+	}
+	return nil
+}
+
+// decodeYAML decodes the top level of the file, then the profile on top of
+// it, so the profile only overrides the keys it sets.
+func decodeYAML(config *Config, data []byte, profile string) error {
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return err
+	}
+	var file struct {
+		Profile  string               `yaml:"profile"`
+		Profiles map[string]yaml.Node `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	if profile == "" {
+		profile = file.Profile
+	}
+	if profile == "" {
+		return nil
+	}
+	node, ok := file.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", profile)
+	}
+	return node.Decode(config)
+}
+
+// decodeTOML is decodeYAML for TOML files, whose profiles are tables such
+// as [profiles.staging].
+func decodeTOML(config *Config, data []byte, profile string) error {
+	if _, err := toml.Decode(string(data), config); err != nil {
+		return err
+	}
+	var file struct {
+		Profile  string                    `toml:"profile"`
+		Profiles map[string]toml.Primitive `toml:"profiles"`
+	}
+	md, err := toml.Decode(string(data), &file)
+	if err != nil {
+		return err
+	}
+	if profile == "" {
+		profile = file.Profile
+	}
+	if profile == "" {
+		return nil
+	}
+	primitive, ok := file.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", profile)
+	}
+	return md.PrimitiveDecode(primitive, config)
+}
+
+func loadEnv(config *Config, getenv func(string) string) error {
+	for name, value := range map[string]*string{
+		"TMDB_API":          &config.API,
+		"TMDB_API_KEY":      &config.APIKey,
+		"TMDB_ACCESS_TOKEN": &config.AccessToken,
+		"TMDB_IMAGE_URL":    &config.ImageURL,
+		"TMDB_CACHE_DIR":    &config.PersistentPath,
+	} {
+		if v := getenv(name); v != "" {
+			*value = v
+		}
+	}
+	if v := getenv("TMDB_CACHE_COMPRESSION"); v != "" {
+		compression, err := ParseCompression(v)
+		if err != nil {
+			return fmt.Errorf("persistent: TMDB_CACHE_COMPRESSION: unknown compression %q", v)
+		}
+		config.Compression = compression
+	}
+	if v := getenv("TMDB_CACHE_POLICY"); v != "" {
+		policy, err := ParsePolicy(v)
+		if err != nil {
+			return fmt.Errorf("persistent: TMDB_CACHE_POLICY: unknown policy %q", v)
+		}
+		config.Policy = policy
+	}
+	if v := getenv("TMDB_CACHE_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("persistent: TMDB_CACHE_MAX_AGE: %w", err)
+		}
+		config.MaxAge = d
+	}
+	if v := getenv("TMDB_CACHE_MAX_SIZE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("persistent: TMDB_CACHE_MAX_SIZE: %w", err)
+		}
+		config.MaxSize = n
+	}
+	return nil
+}
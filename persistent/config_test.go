@@ -0,0 +1,52 @@
+package persistent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigCompression(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		name, file, data string
+		env              string
+		want             Compression
+		ok               bool
+	}{
+		{"yaml", "config.yaml", "compression: ZSTD\n", "", Zstd, true},
+		{"toml", "config.toml", "compression = \"none\"\n", "", NoCompression, true},
+		{"env", "config.yaml", "compression: zstd\n", "gzip", Gzip, true},
+		{"unknown yaml", "config.yaml", "compression: brotli\n", "", "", false},
+		{"unknown toml", "config.toml", "compression = \"brotli\"\n", "", "", false},
+		{"unknown env", "config.yaml", "", "brotli", "", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.file)
+			if err := os.WriteFile(path, []byte(test.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(&LoadOptions{
+				Path: path,
+				Getenv: func(name string) string {
+					if name == "TMDB_CACHE_COMPRESSION" {
+						return test.env
+					}
+					return ""
+				},
+			})
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("got %q, want an error", config.Compression)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Compression != test.want {
+				t.Errorf("got %q, want %q", config.Compression, test.want)
+			}
+		})
+	}
+}
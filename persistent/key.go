@@ -0,0 +1,73 @@
+package persistent
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cacheVersion is bumped whenever the key scheme changes in a way that
+// makes existing entries ambiguous.
+const cacheVersion = 1
+
+const versionFile = ".version"
+
+var (
+	// legacyKey matches the keys written before cache version 1, which were
+	// derived from the query or ID only and ignored every request option.
+	legacyKey  = regexp.MustCompile(`^(movie|movie-search|movie-credits|tv|tv-search|tv-credits|tv-season|tv-episode)-.*\.json$`)
+	currentKey = regexp.MustCompile(`\.[0-9a-f]{16}\.json$`)
+)
+
+// normalise renders a request in canonical form. Credentials and empty
+// parameters are dropped and the rest are sorted, so equivalent requests
+// share an entry while any difference in language, page, region and so on
+// does not.
+func normalise(path string, query url.Values) string {
+	qs := url.Values{}
+	for k := range query {
+		if v := query.Get(k); v != "" && k != "api_key" {
+			qs.Set(k, v)
+		}
+	}
+	return path + "?" + qs.Encode()
+}
+
+// cacheKey derives a cache key from a normalised request.
+func cacheKey(request string) string {
+	sum := sha1.Sum([]byte(request))
+	path, _, _ := strings.Cut(request, "?")
+	resource := strings.ReplaceAll(strings.Trim(path, "/"), "/", "-")
+	return fmt.Sprintf("%s.%s.json", resource, hex.EncodeToString(sum[:8]))
+}
+
+// migrate removes entries written with an older key scheme, then records
+// the current version so it only runs once per cache directory.
+func migrate(dir string) error {
+	filename := filepath.Join(dir, versionFile)
+	if data, err := os.ReadFile(filename); err == nil {
+		if v, _ := strconv.Atoi(strings.TrimSpace(string(data))); v >= cacheVersion {
+			return nil
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !legacyKey.MatchString(name) || currentKey.MatchString(name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.WriteFile(filename, []byte(strconv.Itoa(cacheVersion)), 0644)
+}
@@ -0,0 +1,131 @@
+package persistent
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+func TestNormalise(t *testing.T) {
+	for _, test := range []struct {
+		path, query, want string
+	}{
+		{"/movie/603", "", "/movie/603?"},
+		{"/movie/603", "api_key=secret", "/movie/603?"},
+		{"/search/movie", "query=matrix&page=1&language=fr", "/search/movie?language=fr&page=1&query=matrix"},
+		{"/search/movie", "language=fr&page=1&query=matrix&region=", "/search/movie?language=fr&page=1&query=matrix"},
+		{"/movie/603", "append_to_response=credits,images", "/movie/603?append_to_response=credits%2Cimages"},
+	} {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := normalise(test.path, query); got != test.want {
+			t.Errorf("normalise(%q, %q) = %q, want %q", test.path, test.query, got, test.want)
+		}
+	}
+}
+
+func TestCacheKeyOptions(t *testing.T) {
+	base := "/search/movie?query=matrix"
+	keys := map[string]string{cacheKey(base): base}
+	for _, option := range []string{"language=fr", "page=2", "region=FR", "year=1999", "include_adult=true", "append_to_response=credits"} {
+		request := base + "&" + option
+		key := cacheKey(request)
+		if other, ok := keys[key]; ok {
+			t.Errorf("%q and %q share the key %s", request, other, key)
+		}
+		keys[key] = request
+		if !currentKey.MatchString(key) {
+			t.Errorf("key %s would be removed as a legacy one", key)
+		}
+	}
+	if key := cacheKey("/tv/1399/season/1/episode/2?"); !strings.HasPrefix(key, "tv-1399-season-1-episode-2.") {
+		t.Errorf("cacheKey named the entry %s", key)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	legacy := []string{"movie-603.json", "movie-search-matrix.json", "tv-1399.json", "tv-season-1399-1.json", "tv-episode-1399-1-1.json", "tv-credits-1399.json"}
+	current := []string{cacheKey("/movie/603?"), cacheKey("/search/movie?query=matrix")}
+	for _, name := range append(append([]string{"config.yaml"}, legacy...), current...) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`{"id":603,"title":"Stale"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrate(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range legacy {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("legacy entry %s survived the migration", name)
+		}
+	}
+	for _, name := range append([]string{"config.yaml"}, current...) {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("migration removed %s", name)
+		}
+	}
+
+	// The migration runs once per directory.
+	if err := os.WriteFile(filepath.Join(dir, legacy[0]), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, legacy[0])); err != nil {
+		t.Errorf("second migration removed %s", legacy[0])
+	}
+}
+
+func TestMigratedClientUsesNormalisedKeys(t *testing.T) {
+	dir := t.TempDir()
+	// Written by an older version, which would serve it for any language.
+	if err := os.WriteFile(filepath.Join(dir, "movie-603.json"), []byte(`{"id":603,"title":"Stale"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprintf(w, `{"id":603,"title":"The Matrix %s"}`, r.URL.Query().Get("language"))
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient(&Config{
+		Config:         tmdb.Config{API: server.URL + "/3", APIKey: "key"},
+		PersistentPath: dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, language := range []string{"en", "fr", "en"} {
+		detail, err := client.GetMovieDetail(603, &tmdb.MovieDetailRequest{Language: language})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detail.Title != "The Matrix "+language {
+			t.Errorf("language %s: got title %q", language, detail.Title)
+		}
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("upstream received %d requests, want 2", got)
+	}
+	for _, request := range []string{"/movie/603?language=en", "/movie/603?language=fr"} {
+		_, meta, err := client.Store.Get(cacheKey(request))
+		if err != nil {
+			t.Fatalf("%s not found under its normalised key: %v", request, err)
+		}
+		if meta.Request != request {
+			t.Errorf("entry records request %q, want %q", meta.Request, request)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package persistent
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats summarises the cache. The counters cover the lifetime of the
+// Client, while the entry totals describe the whole store.
+type Stats struct {
+	Hits         int64 `json:"hits"`
+	Misses       int64 `json:"misses"`
+	BytesServed  int64 `json:"bytes_served"`
+	BytesFetched int64 `json:"bytes_fetched"`
+	// StoreErrors counts the responses that could not be cached.
+	StoreErrors int64 `json:"store_errors"`
+
+	Entries  int   `json:"entries"`
+	Size     int64 `json:"size"`
+	NotFound int   `json:"not_found"`
+	// Resources breaks the positive entries down by Resource.
+	Resources map[string]*ResourceStats `json:"resources"`
+}
+
+type ResourceStats struct {
+	Entries int   `json:"entries"`
+	Size    int64 `json:"size"`
+}
+
+// Resource returns the resource type of a cache key, the request path
+// without its IDs, such as "movie", "movie/credits" or "tv/season/episode".
+func Resource(key string) string {
+	key = strings.TrimPrefix(key, notFoundPrefix)
+	if i := strings.IndexByte(key, '.'); i >= 0 {
+		key = key[:i]
+	}
+	var parts []string
+	for _, part := range strings.Split(key, "-") {
+		if _, err := strconv.Atoi(part); err != nil {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (client *Client) Stats() (stats *Stats, err error) {
+	list, err := client.Store.List("")
+	if err != nil {
+		return
+	}
+	counters := &client.transport.stats
+	stats = &Stats{
+		Hits:         counters.hits.Load(),
+		Misses:       counters.misses.Load(),
+		BytesServed:  counters.bytesServed.Load(),
+		BytesFetched: counters.bytesFetched.Load(),
+		StoreErrors:  counters.storeErrors.Load(),
+		Resources:    make(map[string]*ResourceStats),
+	}
+	for _, meta := range list {
+		stats.Entries++
+		stats.Size += meta.storedSize()
+		if strings.HasPrefix(meta.Key, notFoundPrefix) {
+			stats.NotFound++
+			continue
+		}
+		resource := Resource(meta.Key)
+		if stats.Resources[resource] == nil {
+			stats.Resources[resource] = &ResourceStats{}
+		}
+		stats.Resources[resource].Entries++
+		stats.Resources[resource].Size += meta.storedSize()
+	}
+	return
+}
+
+// Entries lists the positive entries of a resource type, or all of them
+// when resource is empty.
+func (client *Client) Entries(resource string) (entries []*Metadata, err error) {
+	list, err := client.Store.List("")
+	if err != nil {
+		return
+	}
+	for _, meta := range list {
+		if strings.HasPrefix(meta.Key, notFoundPrefix) {
+			continue
+		}
+		if resource == "" || Resource(meta.Key) == resource {
+			entries = append(entries, meta)
+		}
+	}
+	return
+}
+
+// Evict removes the least recently used entries until the cache holds at
+// most maxSize bytes.
+func (client *Client) Evict(maxSize int64) (n int, err error) {
+	return client.transport.Evict(maxSize)
+}
+
+// PruneOlderThan removes entries stored more than age ago.
+func (client *Client) PruneOlderThan(age time.Duration) (n int, err error) {
+	return client.prune("", func(meta *Metadata) bool {
+		return time.Since(meta.ModTime) > age
+	})
+}
+
+// PrunePrefix removes entries whose key starts with prefix.
+func (client *Client) PrunePrefix(prefix string) (n int, err error) {
+	return client.prune(prefix, func(*Metadata) bool { return true })
+}
+
+// PurgeNotFound removes every cached not-found response. Positive entries
+// are left alone.
+func (client *Client) PurgeNotFound() (n int, err error) {
+	return client.PrunePrefix(notFoundPrefix)
+}
+
+// Purge empties the cache.
+func (client *Client) Purge() (n int, err error) {
+	return client.PrunePrefix("")
+}
+
+func (client *Client) prune(prefix string, match func(*Metadata) bool) (n int, err error) {
+	list, err := client.Store.List(prefix)
+	if err != nil {
+		return
+	}
+	for _, meta := range list {
+		if !match(meta) {
+			continue
+		}
+		if err = client.Store.Delete(meta.Key); err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
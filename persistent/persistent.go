@@ -1,173 +1,106 @@
 package persistent
 
 import (
-	"encoding/json"
-	"fmt"
-	"net/url"
+	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/song940/tmdb-go/tmdb"
 )
 
+// Config configures a Client. It can be read from a file and the
+// environment with LoadConfig.
 type Config struct {
-	tmdb.Config
+	tmdb.Config `yaml:",inline"`
 
-	PersistentPath string
+	PersistentPath string `yaml:"cache_dir" toml:"cache_dir"`
+	// Compression and Sharded configure the default FileStore, see
+	// FileStore.
+	Compression Compression `yaml:"compression" toml:"compression"`
+	Sharded     bool        `yaml:"sharded" toml:"sharded"`
+	// Store overrides where entries are kept. When nil, a FileStore
+	// rooted at PersistentPath is used.
+	Store Store `yaml:"-" toml:"-"`
+	// Policy selects between the cache and the network, CacheFirst by
+	// default.
+	Policy Policy `yaml:"policy" toml:"policy"`
+	// MaxAge is how long an entry stays fresh. Zero means forever.
+	MaxAge time.Duration `yaml:"max_age" toml:"max_age"`
+	// NotFoundTTL is how long not-found responses are cached, one hour
+	// when zero. A negative value disables negative caching.
+	NotFoundTTL time.Duration `yaml:"not_found_ttl" toml:"not_found_ttl"`
+	// HonorCacheControl lets upstream Cache-Control max-age and Expires
+	// headers decide when an entry goes stale, see Transport.
+	HonorCacheControl bool `yaml:"honor_cache_control" toml:"honor_cache_control"`
+	// MaxSize bounds the size of the cache in bytes, evicting the least
+	// recently used entries. Zero means no limit.
+	MaxSize int64 `yaml:"max_size" toml:"max_size"`
+	// OnStoreError, when set, is called when the store fails to keep or
+	// evict an entry, see Transport.
+	OnStoreError func(key string, err error) `yaml:"-" toml:"-"`
 }
 
+// Client is a tmdb.Client whose GET requests are cached in a Store, so
+// every endpoint, current and future, is cached with the same semantics.
 type Client struct {
 	*tmdb.Client
 	*Config
-}
-
-func NewClient(config *Config) (*Client, error) {
-	if config.PersistentPath == "" {
-		userConfigDir, _ := os.UserConfigDir()
-		config.PersistentPath = filepath.Join(userConfigDir, "tmdb")
-	}
-	if os.MkdirAll(config.PersistentPath, 0755) != nil {
-		return nil, fmt.Errorf("failed to create persistent path: %s", config.PersistentPath)
-	}
-	client, err := tmdb.NewClient(&config.Config)
-	return &Client{
-		Config: config,
-		Client: client,
-	}, err
-}
-
-func (client *Client) SearchMovie(query string, opts *tmdb.SearchMovieRequest) (res *tmdb.SearchMovieResponse, err error) {
-	key := fmt.Sprintf("movie-search-%s.json", url.QueryEscape(query))
-	filename := filepath.Join(client.PersistentPath, key)
-	if f, e := os.Open(filename); e == nil {
-		err = json.NewDecoder(f).Decode(&res)
-		return
-	}
-	res, err = client.Client.SearchMovie(query, opts)
-	if err != nil {
-		return
-	}
-	if data, err := json.Marshal(res); err == nil {
-		os.WriteFile(filename, data, 0644)
-	}
-	return
-}
-
-func (client *Client) GetMovieDetail(id int, opts *tmdb.MovieDetailRequest) (detail *tmdb.MovieDetail, err error) {
-	key := fmt.Sprintf("movie-%d.json", id)
-	filename := filepath.Join(client.PersistentPath, key)
-	if f, e := os.Open(filename); e == nil {
-		err = json.NewDecoder(f).Decode(&detail)
-		return
-	}
-	detail, err = client.Client.GetMovieDetail(id, opts)
-	if err != nil {
-		return
-	}
-	if data, err := json.Marshal(detail); err == nil {
-		os.WriteFile(filename, data, 0644)
-	}
-	return
-}
-
-func (client *Client) GetMovieCredits(id int, opts *tmdb.MovieCreditsRequest) (credits *tmdb.MovieCredits, err error) {
-	key := fmt.Sprintf("movie-credits-%d.json", id)
-	filename := filepath.Join(client.PersistentPath, key)
-	if f, e := os.Open(filename); e == nil {
-		err = json.NewDecoder(f).Decode(&credits)
-		return
-	}
-	credits, err = client.Client.GetMovieCredits(id, opts)
-	if err != nil {
-		return
-	}
-	if data, err := json.Marshal(credits); err == nil {
-		os.WriteFile(filename, data, 0644)
-	}
-	return
-}
 
-func (client *Client) SearchTV(query string, opts *tmdb.SearchTVRequest) (res *tmdb.SearchTVResponse, err error) {
-	key := fmt.Sprintf("tv-search-%s.json", url.QueryEscape(query))
-	filename := filepath.Join(client.PersistentPath, key)
-	if f, e := os.Open(filename); e == nil {
-		err = json.NewDecoder(f).Decode(&res)
-		return
-	}
-	res, err = client.Client.SearchTV(query, opts)
-	if err != nil {
-		return
-	}
-	if data, err := json.Marshal(res); err == nil {
-		os.WriteFile(filename, data, 0644)
-	}
-	return
-}
-
-func (client *Client) GetTVDetail(id int, opts *tmdb.TVDetailRequest) (detail *tmdb.TVDetail, err error) {
-	key := fmt.Sprintf("tv-%d.json", id)
-	filename := filepath.Join(client.PersistentPath, key)
-	if f, e := os.Open(filename); e == nil {
-		err = json.NewDecoder(f).Decode(&detail)
-		return
-	}
-	detail, err = client.Client.GetTVDetail(id, opts)
-	if err != nil {
-		return
-	}
-	if data, err := json.Marshal(detail); err == nil {
-		os.WriteFile(filename, data, 0644)
-	}
-	return
-}
-
-func (client *Client) GetTVCredits(id int, opts *tmdb.TVCreditsRequest) (credits *tmdb.MovieCredits, err error) {
-	key := fmt.Sprintf("tv-credits-%d.json", id)
-	filename := filepath.Join(client.PersistentPath, key)
-	if f, e := os.Open(filename); e == nil {
-		err = json.NewDecoder(f).Decode(&credits)
-		return
-	}
-	credits, err = client.Client.GetTVCredits(id, opts)
-	if err != nil {
-		return
-	}
-	if data, err := json.Marshal(credits); err == nil {
-		os.WriteFile(filename, data, 0644)
-	}
-	return
+	transport  *Transport
+	httpClient *http.Client
 }
 
-func (client *Client) GetTVSeason(id int, season int, opts *tmdb.TVDetailRequest) (detail *tmdb.TVSeasonDetail, err error) {
-	key := fmt.Sprintf("tv-season-%d-%d.json", id, season)
-	filename := filepath.Join(client.PersistentPath, key)
-	if f, e := os.Open(filename); e == nil {
-		err = json.NewDecoder(f).Decode(&detail)
-		return
-	}
-	detail, err = client.Client.GetTVSeason(id, season, opts)
-	if err != nil {
-		return
-	}
-	if data, err := json.Marshal(detail); err == nil {
-		os.WriteFile(filename, data, 0644)
-	}
-	return
+// defaultDir is the directory of the cache and of the config file when
+// neither is configured.
+func defaultDir() string {
+	userConfigDir, _ := os.UserConfigDir()
+	return filepath.Join(userConfigDir, "tmdb")
 }
 
-func (client *Client) GetTVEpisode(seriesId int, seasonNumber int, episodeNumber int, opts *tmdb.TVDetailRequest) (detail *tmdb.TVEpisodeDetail, err error) {
-	key := fmt.Sprintf("tv-episode-%d-%d-%d.json", seriesId, seasonNumber, episodeNumber)
-	filename := filepath.Join(client.PersistentPath, key)
-	if f, e := os.Open(filename); e == nil {
-		err = json.NewDecoder(f).Decode(&detail)
-		return
-	}
-	detail, err = client.Client.GetTVEpisode(seriesId, seasonNumber, episodeNumber, opts)
+func NewClient(config *Config) (*Client, error) {
+	compression, err := ParseCompression(string(config.Compression))
 	if err != nil {
-		return
-	}
-	if data, err := json.Marshal(detail); err == nil {
-		os.WriteFile(filename, data, 0644)
-	}
-	return
+		return nil, err
+	}
+	config.Compression = compression
+	if config.Store == nil {
+		if config.PersistentPath == "" {
+			config.PersistentPath = defaultDir()
+		}
+		store, err := NewFileStore(config.PersistentPath)
+		if err != nil {
+			return nil, err
+		}
+		store.Compression = config.Compression
+		store.Sharded = config.Sharded
+		config.Store = store
+	}
+	transport := &Transport{
+		Store:             config.Store,
+		Policy:            config.Policy,
+		MaxAge:            config.MaxAge,
+		NotFoundTTL:       config.NotFoundTTL,
+		MaxSize:           config.MaxSize,
+		HonorCacheControl: config.HonorCacheControl,
+		OnStoreError:      config.OnStoreError,
+	}
+	httpClient := &http.Client{}
+	if config.HTTPClient != nil {
+		*httpClient = *config.HTTPClient
+		transport.Next = config.HTTPClient.Transport
+	}
+	httpClient.Transport = transport
+	// The embedded client gets its own copy of the config, so that the
+	// caller's HTTPClient is left untouched.
+	tmdbConfig := config.Config
+	tmdbConfig.HTTPClient = httpClient
+	client, err := tmdb.NewClient(&tmdbConfig)
+	transport.Base = tmdbConfig.API
+	return &Client{
+		Config:     config,
+		Client:     client,
+		transport:  transport,
+		httpClient: httpClient,
+	}, err
 }
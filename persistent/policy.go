@@ -0,0 +1,64 @@
+package persistent
+
+import "fmt"
+
+// Policy decides when the cache is consulted and when the network is used.
+type Policy int
+
+const (
+	// CacheFirst serves fresh entries from the cache and fetches
+	// everything else. It is the default.
+	CacheFirst Policy = iota
+	// NetworkOnly always fetches, and refreshes the cache with the result.
+	NetworkOnly
+	// CacheOnly never touches the network and fails with a
+	// *NotCachedError on a miss. Stale entries are still served.
+	CacheOnly
+	// StaleWhileRevalidate serves stale entries immediately and refreshes
+	// them in the background.
+	StaleWhileRevalidate
+)
+
+func (p Policy) String() string {
+	switch p {
+	case CacheFirst:
+		return "cache-first"
+	case NetworkOnly:
+		return "network-only"
+	case CacheOnly:
+		return "cache-only"
+	case StaleWhileRevalidate:
+		return "stale-while-revalidate"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy returns the Policy named s, as printed by String.
+func ParsePolicy(s string) (Policy, error) {
+	for p := CacheFirst; p <= StaleWhileRevalidate; p++ {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("persistent: unknown policy %q", s)
+}
+
+func (p Policy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses a policy name, so that config files can name
+// policies.
+func (p *Policy) UnmarshalText(text []byte) (err error) {
+	*p, err = ParsePolicy(string(text))
+	return
+}
+
+// NotCachedError is returned under CacheOnly when a request has no entry.
+type NotCachedError struct {
+	Key string
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf("persistent: %s is not cached", e.Key)
+}
@@ -0,0 +1,220 @@
+package persistent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+// PrefetchOptions tunes PrefetchTV and PrefetchMovies.
+type PrefetchOptions struct {
+	// Concurrency bounds the number of requests in flight, 4 when zero.
+	Concurrency int
+	// RateLimit caps the number of requests per second. Zero means no cap.
+	RateLimit float64
+	// Retries is how many times a request rejected with 429 Too Many
+	// Requests is retried, with exponential backoff. 3 when zero.
+	Retries  int
+	Language string
+	// Progress, when set, is called after every request. Calls are
+	// serialised.
+	Progress func(Progress)
+}
+
+// Progress reports one finished prefetch request. Total grows as the
+// seasons and episodes of a series are discovered.
+type Progress struct {
+	Task  string
+	Done  int
+	Total int
+	Err   error
+}
+
+// PrefetchTV warms the cache with a series: its details and credits, every
+// season and every episode. Requests already cached are answered from the
+// cache, so running it again after an interruption resumes where it
+// stopped. Failed requests do not stop the others and are joined in the
+// returned error.
+func (client *Client) PrefetchTV(ctx context.Context, id int, opts *PrefetchOptions) error {
+	p := newPrefetcher(ctx, opts)
+	defer p.stop()
+	api := client.bind(p.ctx)
+	lang := &tmdb.TVDetailRequest{Language: p.opts.Language}
+	p.run(fmt.Sprintf("tv %d credits", id), func() error {
+		_, err := api.GetTVCredits(id, &tmdb.TVCreditsRequest{Language: p.opts.Language})
+		return err
+	})
+	p.run(fmt.Sprintf("tv %d", id), func() error {
+		detail, err := api.GetTVDetail(id, lang)
+		if err != nil {
+			return err
+		}
+		for _, season := range detail.Seasons {
+			season := season.SeasonNumber
+			p.run(fmt.Sprintf("tv %d season %d", id, season), func() error {
+				detail, err := api.GetTVSeason(id, season, lang)
+				if err != nil {
+					return err
+				}
+				for _, episode := range detail.Episodes {
+					episode := episode.Episode
+					p.run(fmt.Sprintf("tv %d season %d episode %d", id, season, episode), func() error {
+						_, err := api.GetTVEpisode(id, season, episode, lang)
+						return err
+					})
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return p.wait()
+}
+
+// PrefetchMovies warms the cache with the details and credits of movies.
+// Like PrefetchTV it resumes from the cache when run again.
+func (client *Client) PrefetchMovies(ctx context.Context, ids []int, opts *PrefetchOptions) error {
+	p := newPrefetcher(ctx, opts)
+	defer p.stop()
+	api := client.bind(p.ctx)
+	for _, id := range ids {
+		id := id
+		p.run(fmt.Sprintf("movie %d", id), func() error {
+			_, err := api.GetMovieDetail(id, &tmdb.MovieDetailRequest{Language: p.opts.Language})
+			return err
+		})
+		p.run(fmt.Sprintf("movie %d credits", id), func() error {
+			_, err := api.GetMovieCredits(id, &tmdb.MovieCreditsRequest{Language: p.opts.Language})
+			return err
+		})
+	}
+	return p.wait()
+}
+
+// bind returns a client sharing the cache of client whose requests carry
+// ctx, so that cancelling it also stops the requests in flight.
+func (client *Client) bind(ctx context.Context) *tmdb.Client {
+	config := client.Config.Config
+	config.API = client.transport.Base
+	httpClient := *client.httpClient
+	httpClient.Transport = &contextTransport{ctx: ctx, next: client.transport}
+	config.HTTPClient = &httpClient
+	bound, _ := tmdb.NewClient(&config)
+	return bound
+}
+
+// contextTransport sends requests with its context.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
+
+type prefetcher struct {
+	ctx     context.Context
+	opts    PrefetchOptions
+	sem     chan struct{}
+	limiter *time.Ticker
+	wg      sync.WaitGroup
+
+	mu          sync.Mutex
+	done, total int
+	errs        []error
+}
+
+func newPrefetcher(ctx context.Context, opts *PrefetchOptions) *prefetcher {
+	p := &prefetcher{ctx: ctx}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Concurrency < 1 {
+		p.opts.Concurrency = 4
+	}
+	if p.opts.Retries == 0 {
+		p.opts.Retries = 3
+	}
+	p.sem = make(chan struct{}, p.opts.Concurrency)
+	if p.opts.RateLimit > 0 {
+		p.limiter = time.NewTicker(time.Duration(float64(time.Second) / p.opts.RateLimit))
+		// Only requests that go upstream count against the rate limit,
+		// so resuming from the cache runs at full speed.
+		p.ctx = context.WithValue(ctx, upstreamHook{}, p.throttle)
+	}
+	return p
+}
+
+// run schedules a task. Tasks may schedule further tasks.
+func (p *prefetcher) run(task string, fn func() error) {
+	p.mu.Lock()
+	p.total++
+	p.mu.Unlock()
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		select {
+		case p.sem <- struct{}{}:
+		case <-p.ctx.Done():
+			p.finish(task, p.ctx.Err())
+			return
+		}
+		err := p.call(fn)
+		<-p.sem
+		p.finish(task, err)
+	}()
+}
+
+func (p *prefetcher) call(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		var e *tmdb.Error
+		if !errors.As(err, &e) || e.HTTPStatus != http.StatusTooManyRequests || attempt >= p.opts.Retries {
+			return err
+		}
+		select {
+		case <-time.After(time.Second << attempt):
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+	}
+}
+
+// throttle waits for the rate limit before a request goes upstream.
+func (p *prefetcher) throttle(ctx context.Context) error {
+	select {
+	case <-p.limiter.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *prefetcher) finish(task string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: %w", task, err))
+	}
+	if p.opts.Progress != nil {
+		p.opts.Progress(Progress{Task: task, Done: p.done, Total: p.total, Err: err})
+	}
+}
+
+func (p *prefetcher) wait() error {
+	p.wg.Wait()
+	return errors.Join(p.errs...)
+}
+
+func (p *prefetcher) stop() {
+	if p.limiter != nil {
+		p.limiter.Stop()
+	}
+}
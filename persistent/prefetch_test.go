@@ -0,0 +1,56 @@
+package persistent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPrefetchThrottlesUpstreamOnly(t *testing.T) {
+	client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":1}`)
+	})
+	ids := []int{1, 2, 3}
+	opts := &PrefetchOptions{RateLimit: 20}
+	start := time.Now()
+	if err := client.PrefetchMovies(context.Background(), ids, opts); err != nil {
+		t.Fatal(err)
+	}
+	// Six requests at 20 per second.
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("cold prefetch took %v, want it throttled", elapsed)
+	}
+	fetched := hits.Load()
+	start = time.Now()
+	if err := client.PrefetchMovies(context.Background(), ids, opts); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("prefetch from the cache took %v, want it unthrottled", elapsed)
+	}
+	if got := hits.Load(); got != fetched {
+		t.Errorf("prefetch from the cache sent %d requests upstream", got-fetched)
+	}
+}
+
+func TestPrefetchCancelsRequestsInFlight(t *testing.T) {
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err := client.PrefetchMovies(ctx, []int{1, 2}, nil)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("prefetch returned after %v, want on cancellation", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
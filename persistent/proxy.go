@@ -0,0 +1,192 @@
+package persistent
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/song940/tmdb-go/tmdb"
+	"golang.org/x/time/rate"
+)
+
+// ProxyOptions tunes a Proxy.
+type ProxyOptions struct {
+	// Prefix is the path the API is served under, "/3" when empty, so
+	// that clients only change the host of their API URL.
+	Prefix string
+	// RateLimit is how many requests per second each client address may
+	// send. Zero means no limit.
+	RateLimit float64
+	// Burst is how many requests a client may send at once, the rate
+	// limit rounded up when zero.
+	Burst int
+}
+
+// Proxy is an http.Handler that mirrors the TMDB API from the cache of a
+// Client. Requests carry no credentials: those sent by clients are
+// dropped and the ones of the Client are added, so a single key serves
+// every client. Concurrent identical requests share one upstream call, as
+// for the Client itself. Account and authentication requests, which would
+// act as the owner of the key, are refused.
+type Proxy struct {
+	client *Client
+	opts   *ProxyOptions
+
+	mu       sync.Mutex
+	limiters map[string]*limiter
+	sweep    time.Time
+}
+
+type limiter struct {
+	*rate.Limiter
+	seen time.Time
+}
+
+// idleLimiter is how long the limiter of a silent client is kept.
+const idleLimiter = 10 * time.Minute
+
+// statusRateLimit is the TMDB status code for "Your request count is over
+// the allowed limit."
+const statusRateLimit = 25
+
+// NewProxy returns a Proxy serving from client.
+func NewProxy(client *Client, opts *ProxyOptions) *Proxy {
+	if opts == nil {
+		opts = &ProxyOptions{}
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "/3"
+	}
+	opts.Prefix = "/" + strings.Trim(opts.Prefix, "/")
+	if opts.Burst == 0 {
+		opts.Burst = int(math.Ceil(opts.RateLimit))
+	}
+	return &Proxy{client: client, opts: opts, limiters: make(map[string]*limiter)}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// r.URL.Path is decoded, so that %2e%2e and %3f are caught as well.
+	if strings.Contains(r.URL.Path, "?") || slices.Contains(strings.Split(r.URL.Path, "/"), "..") {
+		p.error(w, http.StatusBadRequest, 0, "Invalid path.")
+		return
+	}
+	rel, ok := strings.CutPrefix(path.Clean(r.URL.Path), p.opts.Prefix+"/")
+	if !ok {
+		p.error(w, http.StatusNotFound, tmdb.StatusNotFound, "The resource you requested could not be found.")
+		return
+	}
+	rel = "/" + rel
+	// They would expose the account of the key.
+	if private(rel) {
+		p.error(w, http.StatusForbidden, 0, "Account and authentication requests are not proxied.")
+		return
+	}
+	// Writes would act on behalf of the account of the key.
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		p.error(w, http.StatusMethodNotAllowed, 0, "Only GET requests are proxied.")
+		return
+	}
+	if !p.allow(r) {
+		w.Header().Set("Retry-After", "1")
+		p.error(w, http.StatusTooManyRequests, statusRateLimit, "Your request count is over the allowed limit.")
+		return
+	}
+	query := r.URL.Query()
+	query.Del("api_key")
+	if p.client.APIKey != "" {
+		query.Set("api_key", p.client.APIKey)
+	}
+	base, err := url.Parse(p.client.transport.Base)
+	if err != nil {
+		p.error(w, http.StatusInternalServerError, 0, err.Error())
+		return
+	}
+	target := &url.URL{
+		Scheme:   base.Scheme,
+		Host:     base.Host,
+		Path:     strings.TrimRight(base.Path, "/") + rel,
+		RawQuery: query.Encode(),
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, target.String(), nil)
+	if err != nil {
+		p.error(w, http.StatusBadRequest, 0, err.Error())
+		return
+	}
+	if p.client.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.client.AccessToken)
+	}
+	res, err := p.client.transport.RoundTrip(req)
+	var notCached *NotCachedError
+	switch {
+	case errors.As(err, &notCached):
+		p.error(w, http.StatusGatewayTimeout, 0, "The resource is not cached.")
+		return
+	case err != nil:
+		// Transport errors carry the upstream URL, which includes the key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		p.error(w, http.StatusBadGateway, 0, err.Error())
+		return
+	}
+	defer res.Body.Close()
+	for _, name := range []string{"Content-Type", "ETag", "Last-Modified", "Cache-Control", "Expires"} {
+		if value := res.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(res.ContentLength, 10))
+	w.WriteHeader(res.StatusCode)
+	if r.Method == http.MethodGet {
+		io.Copy(w, res.Body)
+	}
+}
+
+// allow reports whether the client of r is within its rate limit.
+func (p *Proxy) allow(r *http.Request) bool {
+	if p.opts.RateLimit <= 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now.Sub(p.sweep) > idleLimiter {
+		for key, l := range p.limiters {
+			if now.Sub(l.seen) > idleLimiter {
+				delete(p.limiters, key)
+			}
+		}
+		p.sweep = now
+	}
+	l, ok := p.limiters[host]
+	if !ok {
+		l = &limiter{Limiter: rate.NewLimiter(rate.Limit(p.opts.RateLimit), p.opts.Burst)}
+		p.limiters[host] = l
+	}
+	l.seen = now
+	return l.AllowN(now, 1)
+}
+
+// error answers with a body shaped like those of TMDB, so that clients
+// report it as they would an upstream error.
+func (p *Proxy) error(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tmdb.TMDBResponse{StatusCode: code, StatusMessage: message})
+}
@@ -0,0 +1,117 @@
+package persistent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProxyPaths(t *testing.T) {
+	var mu sync.Mutex
+	var upstream []string
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		upstream = append(upstream, r.URL.Path+"?"+r.URL.RawQuery)
+		mu.Unlock()
+		fmt.Fprint(w, `{"id":603}`)
+	})
+	proxy := NewProxy(client, nil)
+	for _, test := range []struct {
+		path     string
+		status   int
+		upstream string
+	}{
+		{"/3/movie/603?language=fr", http.StatusOK, "/3/movie/603?api_key=key&language=fr"},
+		{"/3//movie/./604/", http.StatusOK, "/3/movie/604?api_key=key"},
+		{"/3/movie/../account", http.StatusBadRequest, ""},
+		{"/3/movie/%2e%2e/%2E%2E/4/list/1", http.StatusBadRequest, ""},
+		{"/3/movie/603%3Fapi_key=other", http.StatusBadRequest, ""},
+		{"/3/account", http.StatusForbidden, ""},
+		{"/3/account/1/favorite/movies", http.StatusForbidden, ""},
+		{"/3/authentication/token/new", http.StatusForbidden, ""},
+		{"/4/list/1", http.StatusNotFound, ""},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			mu.Lock()
+			upstream = nil
+			mu.Unlock()
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if w.Code != test.status {
+				t.Errorf("got status %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case test.upstream == "" && len(upstream) > 0:
+				t.Errorf("requested %v upstream, want nothing", upstream)
+			case test.upstream != "" && (len(upstream) != 1 || upstream[0] != test.upstream):
+				t.Errorf("requested %v upstream, want %s", upstream, test.upstream)
+			}
+		})
+	}
+}
+
+func TestProxyClientDisconnects(t *testing.T) {
+	release := make(chan struct{})
+	client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			fmt.Fprint(w, `{"id":603}`)
+		case <-r.Context().Done():
+		}
+	})
+	server := httptest.NewServer(NewProxy(client, nil))
+	defer server.Close()
+	get := func(ctx context.Context) (int, string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/3/movie/603", nil)
+		if err != nil {
+			return 0, "", err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, "", err
+		}
+		defer res.Body.Close()
+		data, err := io.ReadAll(res.Body)
+		return res.StatusCode, string(data), err
+	}
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	gone := make(chan error, 1)
+	go func() {
+		_, _, err := get(ctx)
+		gone <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	staying := make(chan result, 1)
+	go func() {
+		status, body, err := get(context.Background())
+		staying <- result{status, body, err}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	disconnect()
+	if err := <-gone; !errors.Is(err, context.Canceled) {
+		t.Errorf("disconnected client got %v", err)
+	}
+	// Let the proxy notice the disconnection before upstream answers.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if r := <-staying; r.err != nil || r.status != http.StatusOK || r.body != `{"id":603}` {
+		t.Errorf("remaining client got %d %q, %v", r.status, r.body, r.err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("upstream received %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,111 @@
+package persistent
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by a Store when no entry exists for a key.
+	ErrNotFound = errors.New("persistent: entry not found")
+	// ErrCorrupt is returned by a Store when an entry exists but fails
+	// validation, for example after a crash in the middle of a write.
+	ErrCorrupt = errors.New("persistent: corrupt entry")
+)
+
+// accessResolution is how stale an access time may get before a read
+// updates it, which spares a write on most cache hits.
+const accessResolution = time.Minute
+
+// Metadata describes a cached entry without its payload.
+type Metadata struct {
+	Key string `json:"key"`
+	// Size is the length of the payload.
+	Size int64 `json:"size"`
+	// DiskSize is the space the entry takes in the store, with its header
+	// and after compression. It is filled by List in stores that know it.
+	DiskSize int64 `json:"-"`
+	// ModTime is when the entry was stored.
+	ModTime time.Time `json:"mod_time"`
+	// AccessTime is when the entry was last read or stored, which drives
+	// least recently used eviction. Stores may only refresh it once it is
+	// older than accessResolution.
+	AccessTime time.Time `json:"access_time"`
+	// Checksum is the hex encoded SHA-256 of the payload, when the store
+	// records one.
+	Checksum string `json:"checksum,omitempty"`
+	// Request is the normalised request the entry answers, such as
+	// "/movie/603?language=fr".
+	Request string `json:"request,omitempty"`
+	// Schema is the tmdb.ModelVersion of the library that stored the
+	// entry, zero for entries stored before versioning.
+	Schema int `json:"schema,omitempty"`
+	// ETag and LastModified are the validators sent by upstream, used to
+	// revalidate the entry with a conditional request once it is stale.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Expires is when the entry goes stale according to upstream
+	// Cache-Control or Expires headers, if they gave one.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// newMetadata completes the metadata of an entry about to be stored. The
+// fields of meta, which may be nil, are kept unless derived from data.
+func newMetadata(key string, data []byte, meta *Metadata) *Metadata {
+	m := &Metadata{}
+	if meta != nil {
+		*m = *meta
+	}
+	now := time.Now()
+	m.Key = key
+	m.Size = int64(len(data))
+	m.AccessTime = now
+	if m.ModTime.IsZero() {
+		m.ModTime = now
+	}
+	m.Checksum = checksum(data)
+	return m
+}
+
+// storedSize returns the space the entry takes in its store, its payload
+// size when the store does not report it.
+func (m *Metadata) storedSize() int64 {
+	if m.DiskSize > 0 {
+		return m.DiskSize
+	}
+	return m.Size
+}
+
+// Store is the storage backend of the persistent cache. Implementations
+// must be safe for concurrent use.
+type Store interface {
+	// Get returns the payload and metadata of the entry stored under key,
+	// or ErrNotFound.
+	Get(key string) (data []byte, meta *Metadata, err error)
+	// Set stores data under key, replacing any existing entry. Fields of
+	// meta other than those derived from the payload are recorded, and
+	// ModTime defaults to now when zero. meta may be nil.
+	Set(key string, data []byte, meta *Metadata) error
+	// Delete removes the entry stored under key. Deleting a missing key
+	// is not an error.
+	Delete(key string) error
+	// List returns the metadata of every entry whose key starts with
+	// prefix, sorted by key.
+	List(prefix string) ([]*Metadata, error)
+}
+
+// Peeker is implemented by stores that can read an entry without the side
+// effects of Get, such as refreshing its access time, so that reading the
+// whole store leaves the order of eviction intact.
+type Peeker interface {
+	Peek(key string) (data []byte, meta *Metadata, err error)
+}
+
+// peek reads an entry with Peek when store implements it, and Get
+// otherwise.
+func peek(store Store, key string) ([]byte, *Metadata, error) {
+	if p, ok := store.(Peeker); ok {
+		return p.Peek(key)
+	}
+	return store.Get(key)
+}
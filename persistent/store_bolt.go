@@ -0,0 +1,135 @@
+package persistent
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltDataBucket = []byte("data")
+	boltMetaBucket = []byte("meta")
+)
+
+// BoltStore keeps entries in a single embedded bbolt database file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens or creates the database at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltDataBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases the database file.
+func (store *BoltStore) Close() error {
+	return store.db.Close()
+}
+
+// Get reads in a read-only transaction, so hits do not queue behind the
+// single writer. The access time is written back only once it is older
+// than accessResolution, in a batch shared with concurrent writers.
+func (store *BoltStore) Get(key string) (data []byte, meta *Metadata, err error) {
+	if data, meta, err = store.Peek(key); err != nil {
+		return
+	}
+	if time.Since(meta.AccessTime) > accessResolution {
+		store.touch(key)
+	}
+	return
+}
+
+// Peek is Get without refreshing the access time.
+func (store *BoltStore) Peek(key string) (data []byte, meta *Metadata, err error) {
+	err = store.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(boltMetaBucket).Get([]byte(key))
+		if raw == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return err
+		}
+		data = append([]byte(nil), tx.Bucket(boltDataBucket).Get([]byte(key))...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// touch sets the access time of key to now, unless the entry was replaced
+// or removed in the meantime.
+func (store *BoltStore) touch(key string) {
+	store.db.Batch(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltMetaBucket)
+		raw := bucket.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		var meta Metadata
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil
+		}
+		meta.AccessTime = time.Now()
+		raw, err := json.Marshal(&meta)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), raw)
+	})
+}
+
+func (store *BoltStore) Set(key string, data []byte, meta *Metadata) error {
+	raw, err := json.Marshal(newMetadata(key, data, meta))
+	if err != nil {
+		return err
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltDataBucket).Put([]byte(key), data); err != nil {
+			return err
+		}
+		return tx.Bucket(boltMetaBucket).Put([]byte(key), raw)
+	})
+}
+
+func (store *BoltStore) Delete(key string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltDataBucket).Delete([]byte(key)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltMetaBucket).Delete([]byte(key))
+	})
+}
+
+func (store *BoltStore) List(prefix string) (list []*Metadata, err error) {
+	err = store.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltMetaBucket).Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			var meta *Metadata
+			if err := json.Unmarshal(v, &meta); err != nil {
+				return err
+			}
+			list = append(list, meta)
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,329 @@
+package persistent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileMagic starts the header line written in front of every payload. The
+// header records the payload length and checksum, so a truncated or
+// otherwise damaged file is detected instead of being served.
+const fileMagic = "TMDBCACHE1 "
+
+const tempPrefix = ".tmp-"
+
+// fileHeader is the JSON in the header line.
+type fileHeader struct {
+	Metadata
+	// Encoding is the Compression of the payload that follows.
+	Encoding Compression `json:"encoding,omitempty"`
+}
+
+// FileStore keeps one file per entry. The time an entry was stored is kept
+// in its header, while the modification time of the file tracks when it
+// was last accessed, to within accessResolution so that most reads do not
+// write.
+//
+// Entries are read from both the flat and the sharded layout whatever the
+// settings, and moved to the configured one when read, so either setting
+// can be changed on an existing directory.
+type FileStore struct {
+	// Compression applies to entries written from now on.
+	Compression Compression
+	// Sharded spreads entries over two levels of subdirectories named
+	// after the hash of their key, such as ab/cd/key, which keeps
+	// directories small in very large caches. Otherwise all entries share
+	// one flat directory.
+	Sharded bool
+
+	dir string
+}
+
+// NewFileStore creates dir if needed, migrates entries left by older
+// versions of the cache and removes temporary files abandoned by a crash.
+func NewFileStore(dir string) (*FileStore, error) {
+	if os.MkdirAll(dir, 0755) != nil {
+		return nil, fmt.Errorf("failed to create persistent path: %s", dir)
+	}
+	if err := migrate(dir); err != nil {
+		return nil, fmt.Errorf("failed to migrate persistent path: %w", err)
+	}
+	store := &FileStore{dir: dir}
+	store.removeTemp(time.Hour)
+	return store, nil
+}
+
+func (store *FileStore) flat(key string) string {
+	return filepath.Join(store.dir, escape(key))
+}
+
+func (store *FileStore) sharded(key string) string {
+	sum := sha1.Sum([]byte(key))
+	h := hex.EncodeToString(sum[:2])
+	return filepath.Join(store.dir, h[:2], h[2:], escape(key))
+}
+
+// escape returns the file name of key. Separators are escaped rather than
+// dropped, so that keys differing before a "/" get files of their own,
+// and a leading dot too, so that no key names a hidden file or a parent
+// directory. Keys derived from requests are their own file names.
+func escape(key string) string {
+	name := url.PathEscape(key)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return name
+}
+
+// unescape returns the key of a file name.
+func unescape(name string) string {
+	if key, err := url.PathUnescape(name); err == nil {
+		return key
+	}
+	return name
+}
+
+// filenames returns where key belongs under the current layout, then where
+// it may be left over from the other one.
+func (store *FileStore) filenames(key string) (filename, other string) {
+	if store.Sharded {
+		return store.sharded(key), store.flat(key)
+	}
+	return store.flat(key), store.sharded(key)
+}
+
+// removeTemp deletes temporary files older than age. Younger ones may
+// still belong to a write in progress in another process.
+func (store *FileStore) removeTemp(age time.Duration) {
+	matches, _ := filepath.Glob(filepath.Join(store.dir, tempPrefix+"*"))
+	for _, name := range matches {
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > age {
+			os.Remove(name)
+		}
+	}
+}
+
+func (store *FileStore) Get(key string) (data []byte, meta *Metadata, err error) {
+	return store.read(key, true)
+}
+
+// Peek is Get without refreshing the access time, nor moving the entry to
+// the configured layout.
+func (store *FileStore) Peek(key string) (data []byte, meta *Metadata, err error) {
+	return store.read(key, false)
+}
+
+func (store *FileStore) read(key string, touch bool) (data []byte, meta *Metadata, err error) {
+	filename, other := store.filenames(key)
+	raw, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		raw, err = os.ReadFile(other)
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		if !touch {
+			filename = other
+		} else if err == nil && os.MkdirAll(filepath.Dir(filename), 0755) == nil {
+			if os.Rename(other, filename) != nil {
+				filename = other
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+	meta, data, err = decodeFile(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, key, err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta.Key = key
+	meta.AccessTime = info.ModTime()
+	if meta.ModTime.IsZero() {
+		// Entries without a header only have the file time to go by, so
+		// it is left alone.
+		meta.ModTime = info.ModTime()
+		return
+	}
+	if touch && time.Since(meta.AccessTime) > accessResolution {
+		now := time.Now()
+		os.Chtimes(filename, now, now)
+	}
+	return
+}
+
+// Set writes the entry to a temporary file and renames it into place, so
+// readers never observe a partially written entry.
+func (store *FileStore) Set(key string, data []byte, meta *Metadata) (err error) {
+	meta = newMetadata(key, data, meta)
+	header, err := json.Marshal(&fileHeader{Metadata: *meta, Encoding: store.Compression})
+	if err != nil {
+		return
+	}
+	payload, err := compress(store.Compression, data)
+	if err != nil {
+		return
+	}
+	filename, other := store.filenames(key)
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(store.dir, tempPrefix+"*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	w := bufio.NewWriter(f)
+	w.WriteString(fileMagic)
+	w.Write(header)
+	w.WriteByte('\n')
+	w.Write(payload)
+	if err = w.Flush(); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return
+	}
+	if err = os.Chtimes(f.Name(), meta.AccessTime, meta.AccessTime); err != nil {
+		return
+	}
+	if err = os.Rename(f.Name(), filename); err != nil {
+		return
+	}
+	if err := os.Remove(other); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// diskSize returns the size of the file of key, which the transport uses
+// to track the size of the store between evictions.
+func (store *FileStore) diskSize(key string) int64 {
+	filename, _ := store.filenames(key)
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func (store *FileStore) Delete(key string) error {
+	filename, other := store.filenames(key)
+	for _, name := range []string{filename, other} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// List walks both layouts.
+func (store *FileStore) List(prefix string) (list []*Metadata, err error) {
+	seen := make(map[string]bool)
+	err = filepath.WalkDir(store.dir, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		key := unescape(name)
+		// Skip temporary files, and files that are not entries such as a
+		// config file sharing the directory.
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(key, ".json") || !strings.HasPrefix(key, prefix) || seen[key] {
+			return nil
+		}
+		meta, err := stat(filename)
+		if err != nil {
+			return nil
+		}
+		seen[key] = true
+		list = append(list, meta)
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return
+}
+
+// stat reads only the header of an entry.
+func stat(filename string) (meta *Metadata, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+	meta = &Metadata{Size: info.Size()}
+	line, _ := bufio.NewReader(f).ReadSlice('\n')
+	if bytes.HasPrefix(line, []byte(fileMagic)) {
+		json.Unmarshal(line[len(fileMagic):], meta)
+	}
+	meta.Key = unescape(filepath.Base(filename))
+	meta.DiskSize = info.Size()
+	meta.AccessTime = info.ModTime()
+	if meta.ModTime.IsZero() {
+		meta.ModTime = info.ModTime()
+	}
+	return
+}
+
+func decodeFile(raw []byte) (meta *Metadata, data []byte, err error) {
+	if !bytes.HasPrefix(raw, []byte(fileMagic)) {
+		// Entries written before headers were introduced are plain JSON.
+		if !json.Valid(raw) {
+			return nil, nil, fmt.Errorf("invalid JSON")
+		}
+		return &Metadata{Size: int64(len(raw))}, raw, nil
+	}
+	i := bytes.IndexByte(raw, '\n')
+	if i < 0 {
+		return nil, nil, fmt.Errorf("truncated header")
+	}
+	var header fileHeader
+	if err = json.Unmarshal(raw[len(fileMagic):i], &header); err != nil {
+		return nil, nil, err
+	}
+	meta = &header.Metadata
+	if data, err = decompress(header.Encoding, raw[i+1:]); err != nil {
+		return nil, nil, err
+	}
+	if int64(len(data)) != meta.Size {
+		return nil, nil, fmt.Errorf("size %d, expected %d", len(data), meta.Size)
+	}
+	if sum := checksum(data); sum != meta.Checksum {
+		return nil, nil, fmt.Errorf("checksum %s, expected %s", sum, meta.Checksum)
+	}
+	return
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,291 @@
+package persistent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKey = "movie-603.0123456789abcdef.json"
+
+// writeEntry stores a complete entry and returns its file contents.
+func writeEntry(t *testing.T, store *FileStore) []byte {
+	t.Helper()
+	if err := store.Set(testKey, []byte(`{"id":603,"title":"The Matrix"}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(store.flat(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestFileStorePartialWrites(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cut  func(raw []byte) []byte
+	}{
+		{"truncated payload", func(raw []byte) []byte { return raw[:len(raw)-5] }},
+		{"header without payload", func(raw []byte) []byte {
+			for i, b := range raw {
+				if b == '\n' {
+					return raw[:i+1]
+				}
+			}
+			t.Fatal("no header line")
+			return nil
+		}},
+		{"truncated header", func(raw []byte) []byte { return raw[:len(fileMagic)+10] }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			store, err := NewFileStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := writeEntry(t, store)
+			if err := os.WriteFile(store.flat(testKey), test.cut(raw), 0644); err != nil {
+				t.Fatal(err)
+			}
+			data, meta, err := store.Get(testKey)
+			if !errors.Is(err, ErrCorrupt) {
+				t.Fatalf("Get: got error %v, want ErrCorrupt", err)
+			}
+			if data != nil || meta != nil {
+				t.Fatalf("Get returned %q with the error", data)
+			}
+		})
+	}
+}
+
+func TestFileStoreLeftoverTemp(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := writeEntry(t, store)
+	// A crash between the write and the rename leaves a temporary file
+	// behind, possibly incomplete, while the entry itself was never
+	// renamed into place.
+	temp := filepath.Join(dir, tempPrefix+"123")
+	if err := os.WriteFile(temp, raw[:len(raw)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(testKey); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, err := store.Get(testKey); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get: got %q, %v, want ErrNotFound", data, err)
+	}
+	list, err := store.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("List: got %d entries, want none", len(list))
+	}
+
+	// Reopening removes temporary files old enough to be abandoned.
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(temp, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(temp); !os.IsNotExist(err) {
+		t.Fatalf("temporary file still present: %v", err)
+	}
+}
+
+func TestFileStoreLayouts(t *testing.T) {
+	data := []byte(`{"id":603,"title":"The Matrix","overview":"` + strings.Repeat("Neo ", 100) + `"}`)
+	for _, compression := range []Compression{NoCompression, Gzip, Zstd} {
+		for _, sharded := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s sharded=%v", compression, sharded), func(t *testing.T) {
+				store, err := NewFileStore(t.TempDir())
+				if err != nil {
+					t.Fatal(err)
+				}
+				store.Compression = compression
+				store.Sharded = sharded
+				if err := store.Set(testKey, data, nil); err != nil {
+					t.Fatal(err)
+				}
+				filename, other := store.flat(testKey), store.sharded(testKey)
+				if sharded {
+					filename, other = other, filename
+				}
+				raw, err := os.ReadFile(filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := os.Stat(other); !os.IsNotExist(err) {
+					t.Errorf("entry also found at %s", other)
+				}
+				if compressed := !bytes.Contains(raw, data); compressed != (compression != NoCompression) {
+					t.Errorf("payload compressed is %v on disk", compressed)
+				}
+				got, meta, err := store.Get(testKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) || meta.Size != int64(len(data)) {
+					t.Errorf("Get = %q, size %d", got, meta.Size)
+				}
+				list, err := store.List("")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(list) != 1 || list[0].Key != testKey || list[0].DiskSize != int64(len(raw)) {
+					t.Errorf("List = %+v", list)
+				}
+
+				// Changing the settings keeps existing entries readable.
+				store.Compression = Zstd
+				if compression == Zstd {
+					store.Compression = Gzip
+				}
+				if got, _, err := store.Get(testKey); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("Get after changing compression = %q, %v", got, err)
+				}
+			})
+		}
+	}
+}
+
+func TestFileStoreMigratesToShards(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{testKey, "tv-1399.0123456789abcdef.json", "search-movie.0123456789abcdef.json"}
+	for _, key := range keys {
+		if err := store.Set(key, []byte(`{}`), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Sharded = true
+	list, err := store.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(keys) {
+		t.Fatalf("List found %d flat entries, want %d", len(list), len(keys))
+	}
+	// Peek leaves entries where they are.
+	if _, _, err := store.Peek(testKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(store.flat(testKey)); err != nil {
+		t.Errorf("Peek moved the entry: %v", err)
+	}
+	for _, key := range keys {
+		if _, _, err := store.Get(key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(store.sharded(key)); err != nil {
+			t.Errorf("%s not moved into its shard: %v", key, err)
+		}
+		if _, err := os.Stat(store.flat(key)); !os.IsNotExist(err) {
+			t.Errorf("%s still in the flat layout", key)
+		}
+	}
+	if list, err = store.List(""); err != nil || len(list) != len(keys) {
+		t.Errorf("List found %d sharded entries, %v, want %d", len(list), err, len(keys))
+	}
+
+	// And back again.
+	store.Sharded = false
+	for _, key := range keys {
+		if _, _, err := store.Get(key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(store.flat(key)); err != nil {
+			t.Errorf("%s not moved back to the flat layout: %v", key, err)
+		}
+	}
+}
+
+func TestFileStoreAccessTime(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEntry(t, store)
+	filename := store.flat(testKey)
+	for _, test := range []struct {
+		age     time.Duration
+		touched bool
+	}{
+		{time.Second, false},
+		{accessResolution / 2, false},
+		{2 * accessResolution, true},
+	} {
+		accessed := time.Now().Add(-test.age)
+		if err := os.Chtimes(filename, accessed, accessed); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := store.Get(testKey); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if touched := !info.ModTime().Equal(accessed); touched != test.touched {
+			t.Errorf("accessed %v ago: access time refreshed is %v, want %v", test.age, touched, test.touched)
+		}
+	}
+}
+
+func TestFileStoreKeys(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"%2E.json", "../movie.json", ".hidden.json", "100%.json", "a/movie.json", "b/movie.json", "movie.json"}
+	for _, sharded := range []bool{false, true} {
+		store.Sharded = sharded
+		for i, key := range keys {
+			if err := store.Set(key, []byte(fmt.Sprint(i)), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, key := range keys {
+			if data, _, err := store.Get(key); err != nil || string(data) != fmt.Sprint(i) {
+				t.Errorf("Get(%q) = %q, %v, want %d", key, data, err, i)
+			}
+		}
+		list, err := store.List("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, meta := range list {
+			got = append(got, meta.Key)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(keys) {
+			t.Errorf("List = %q, want %q", got, keys)
+		}
+		if list, err := store.List("a/"); err != nil || len(list) != 1 {
+			t.Errorf(`List("a/") found %d entries, %v`, len(list), err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "movie.json")); !os.IsNotExist(err) {
+		t.Error("an entry was written outside the store")
+	}
+}
@@ -0,0 +1,105 @@
+package persistent
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	meta Metadata
+	data []byte
+}
+
+// MemoryStore is an in-memory Store that evicts the least recently used
+// entries once it holds more than MaxEntries.
+type MemoryStore struct {
+	MaxEntries int
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// NewMemoryStore returns a MemoryStore holding at most maxEntries entries.
+// A maxEntries of zero means no limit.
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		MaxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (store *MemoryStore) Get(key string) (data []byte, meta *Metadata, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	el, ok := store.entries[key]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	store.lru.MoveToFront(el)
+	entry := el.Value.(*memoryEntry)
+	m := entry.meta
+	entry.meta.AccessTime = time.Now()
+	return append([]byte(nil), entry.data...), &m, nil
+}
+
+// Peek is Get without moving the entry to the front of the LRU list.
+func (store *MemoryStore) Peek(key string) (data []byte, meta *Metadata, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	el, ok := store.entries[key]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	entry := el.Value.(*memoryEntry)
+	m := entry.meta
+	return append([]byte(nil), entry.data...), &m, nil
+}
+
+func (store *MemoryStore) Set(key string, data []byte, meta *Metadata) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	entry := &memoryEntry{
+		meta: *newMetadata(key, data, meta),
+		data: append([]byte(nil), data...),
+	}
+	if el, ok := store.entries[key]; ok {
+		el.Value = entry
+		store.lru.MoveToFront(el)
+		return nil
+	}
+	store.entries[key] = store.lru.PushFront(entry)
+	for store.MaxEntries > 0 && store.lru.Len() > store.MaxEntries {
+		oldest := store.lru.Back()
+		store.lru.Remove(oldest)
+		delete(store.entries, oldest.Value.(*memoryEntry).meta.Key)
+	}
+	return nil
+}
+
+func (store *MemoryStore) Delete(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if el, ok := store.entries[key]; ok {
+		store.lru.Remove(el)
+		delete(store.entries, key)
+	}
+	return nil
+}
+
+func (store *MemoryStore) List(prefix string) (list []*Metadata, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for key, el := range store.entries {
+		if strings.HasPrefix(key, prefix) {
+			m := el.Value.(*memoryEntry).meta
+			list = append(list, &m)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return
+}
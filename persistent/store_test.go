@@ -0,0 +1,192 @@
+package persistent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stores returns a fresh instance of every Store implementation.
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	file, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+	return map[string]Store{"memory": NewMemoryStore(0), "bolt": bolt, "file": file}
+}
+
+func TestStoreConformance(t *testing.T) {
+	for name, store := range stores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			data := []byte(`{"id":603,"title":"The Matrix"}`)
+			if _, _, err := store.Get(testKey); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get of a missing key: got %v, want ErrNotFound", err)
+			}
+			if err := store.Delete(testKey); err != nil {
+				t.Fatalf("Delete of a missing key: %v", err)
+			}
+
+			before := time.Now().Add(-time.Second)
+			if err := store.Set(testKey, data, &Metadata{Request: "/movie/603?", Schema: 2, ETag: `"v1"`}); err != nil {
+				t.Fatal(err)
+			}
+			got, meta, err := store.Get(testKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("Get = %q, want %q", got, data)
+			}
+			if meta.Key != testKey || meta.Size != int64(len(data)) || meta.Checksum != checksum(data) {
+				t.Errorf("Get metadata = %+v", meta)
+			}
+			if meta.Request != "/movie/603?" || meta.Schema != 2 || meta.ETag != `"v1"` {
+				t.Errorf("Get lost the recorded metadata: %+v", meta)
+			}
+			if meta.ModTime.Before(before) || meta.AccessTime.Before(before) {
+				t.Errorf("Get times %v and %v, want now", meta.ModTime, meta.AccessTime)
+			}
+			if p, ok := store.(Peeker); ok {
+				if got, _, err := p.Peek(testKey); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("Peek = %q, %v", got, err)
+				}
+			}
+
+			// Set replaces the entry, and keeps a given ModTime.
+			old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+			data = []byte(`{"id":603,"title":"Matrix"}`)
+			if err := store.Set(testKey, data, &Metadata{ModTime: old}); err != nil {
+				t.Fatal(err)
+			}
+			got, meta, err = store.Get(testKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) || !meta.ModTime.Equal(old) || meta.ETag != "" {
+				t.Errorf("Get after replace = %q, %+v", got, meta)
+			}
+
+			for _, key := range []string{"tv-1399.0123456789abcdef.json", notFoundPrefix + testKey} {
+				if err := store.Set(key, []byte(`{}`), nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for prefix, want := range map[string][]string{
+				"":             {testKey, notFoundPrefix + testKey, "tv-1399.0123456789abcdef.json"},
+				"movie-":       {testKey},
+				notFoundPrefix: {notFoundPrefix + testKey},
+				"person-":      nil,
+			} {
+				list, err := store.List(prefix)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var keys []string
+				for _, meta := range list {
+					keys = append(keys, meta.Key)
+				}
+				if fmt.Sprint(keys) != fmt.Sprint(want) {
+					t.Errorf("List(%q) = %v, want %v", prefix, keys, want)
+				}
+			}
+
+			if err := store.Delete(testKey); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := store.Get(testKey); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	for name, store := range stores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(&Config{Store: store})
+			if err != nil {
+				t.Fatal(err)
+			}
+			old := &Metadata{ModTime: time.Now().Add(-48 * time.Hour)}
+			for key, meta := range map[string]*Metadata{
+				"movie-603.0123456789abcdef.json": old,
+				"movie-604.0123456789abcdef.json": nil,
+				"tv-1399.0123456789abcdef.json":   old,
+				notFoundPrefix + "movie-1.0.json": nil,
+				notFoundPrefix + "movie-2.0.json": nil,
+			} {
+				if err := store.Set(key, []byte(`{}`), meta); err != nil {
+					t.Fatal(err)
+				}
+			}
+			count := func() int {
+				list, err := store.List("")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(list)
+			}
+			for _, step := range []struct {
+				name  string
+				prune func() (int, error)
+				n     int
+			}{
+				{"PruneOlderThan", func() (int, error) { return client.PruneOlderThan(24 * time.Hour) }, 2},
+				{"PurgeNotFound", client.PurgeNotFound, 2},
+				{"PrunePrefix", func() (int, error) { return client.PrunePrefix("tv-") }, 0},
+				{"Purge", client.Purge, 1},
+			} {
+				before := count()
+				n, err := step.prune()
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if n != step.n || count() != before-n {
+					t.Errorf("%s removed %d of %d entries, %d left, want %d removed", step.name, n, before, count(), step.n)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreConcurrentUse(t *testing.T) {
+	for name, store := range stores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				i := i
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					key := fmt.Sprintf("movie-%d.0123456789abcdef.json", i%2)
+					data := []byte(fmt.Sprintf(`{"id":%d}`, i%2))
+					for j := 0; j < 20; j++ {
+						if err := store.Set(key, data, nil); err != nil {
+							t.Error(err)
+							return
+						}
+						if got, _, err := store.Get(key); err != nil || !bytes.Equal(got, data) {
+							t.Errorf("Get(%q) = %q, %v", key, got, err)
+							return
+						}
+						store.List("")
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
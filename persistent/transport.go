@@ -0,0 +1,474 @@
+package persistent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/song940/tmdb-go/tmdb"
+	"golang.org/x/sync/singleflight"
+)
+
+// Transport is an http.RoundTripper that serves GET requests from a Store
+// and stores successful responses, so every endpoint of tmdb.Client is
+// cached the same way. Concurrent requests for the same key share a single
+// upstream call and cache write, which runs until the last of them is
+// cancelled.
+type Transport struct {
+	Store Store
+	// Base is the API root, whose path is stripped when deriving keys so
+	// that entries survive a change of API host.
+	Base string
+	// Next sends requests that miss the cache, http.DefaultTransport
+	// when nil.
+	Next http.RoundTripper
+	// Policy selects between the cache and the network.
+	Policy Policy
+	// MaxAge is how long an entry stays fresh. Zero means entries never
+	// go stale, in which case StaleWhileRevalidate behaves as CacheFirst.
+	MaxAge time.Duration
+	// NotFoundTTL is how long a not-found response is remembered, so that
+	// deleted or mistyped IDs are not queried again on every run. Zero
+	// means DefaultNotFoundTTL and a negative value disables it.
+	NotFoundTTL time.Duration
+	// HonorCacheControl makes the max-age of upstream Cache-Control, or
+	// failing that Expires, decide when an entry goes stale. MaxAge still
+	// applies to entries without either header.
+	HonorCacheControl bool
+	// MaxSize bounds the total size of the store in bytes, as stored on
+	// disk after compression. Once exceeded, the least recently used
+	// entries are evicted down to 90% of it. Zero means no limit.
+	MaxSize int64
+	// OnStoreError, when set, is called when the store fails to keep an
+	// entry or to evict others. The response is still returned, uncached.
+	// Failures are also counted in Stats.StoreErrors.
+	OnStoreError func(key string, err error)
+
+	group singleflight.Group
+	stats counters
+
+	mu    sync.Mutex
+	calls map[string]*call
+
+	evicting sync.Mutex
+	sizeOnce sync.Once
+	size     atomic.Int64
+}
+
+type counters struct {
+	hits, misses, bytesServed, bytesFetched, storeErrors atomic.Int64
+}
+
+// DefaultNotFoundTTL is used when Transport.NotFoundTTL is zero.
+const DefaultNotFoundTTL = time.Hour
+
+// notFoundPrefix namespaces the tombstones left by not-found responses, so
+// they can be listed and purged apart from positive entries.
+const notFoundPrefix = "notfound-"
+
+// upstreamHook is the context key of a func(context.Context) error called
+// before a request is sent upstream, which may delay or refuse it. Requests
+// answered from the cache do not call it.
+type upstreamHook struct{}
+
+// response is a fully read response that can be handed to several callers.
+type response struct {
+	status int
+	header http.Header
+	data   []byte
+	// cached is set when the response was served from the store.
+	cached bool
+}
+
+// call is an upstream call shared by the callers waiting on a key. Its
+// context carries the values of the first request but outlives it, and is
+// cancelled once every caller has given up.
+type call struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+func (t *Transport) next() http.RoundTripper {
+	if t.Next != nil {
+		return t.Next
+	}
+	return http.DefaultTransport
+}
+
+// request returns the normalised form of req, relative to Base.
+func (t *Transport) request(req *http.Request) string {
+	path := req.URL.Path
+	if base, err := url.Parse(t.Base); err == nil {
+		path = strings.TrimPrefix(path, strings.TrimRight(base.Path, "/"))
+	}
+	return normalise(path, req.URL.Query())
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next().RoundTrip(req)
+	}
+	request := t.request(req)
+	if private(request) {
+		return t.next().RoundTrip(req)
+	}
+	key := cacheKey(request)
+	c := t.join(key, req)
+	ch := t.group.DoChan(key, func() (any, error) {
+		return t.fetch(req.WithContext(c.ctx), key)
+	})
+	select {
+	case r := <-ch:
+		t.leave(key, c)
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		res := r.Val.(*response)
+		// Every caller counts, whether it led the call or waited on it.
+		if res.cached {
+			t.hit(res)
+		} else {
+			t.stats.misses.Add(1)
+		}
+		return res.build(req), nil
+	case <-req.Context().Done():
+		t.leave(key, c)
+		return nil, req.Context().Err()
+	}
+}
+
+// join registers req as waiting on the call for key, creating the call if
+// there is none.
+func (t *Transport) join(key string, req *http.Request) *call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.calls == nil {
+		t.calls = make(map[string]*call)
+	}
+	c, ok := t.calls[key]
+	if !ok {
+		c = &call{}
+		c.ctx, c.cancel = context.WithCancel(context.WithoutCancel(req.Context()))
+		t.calls[key] = c
+	}
+	c.waiters++
+	return c
+}
+
+// leave unregisters a caller of c, and cancels c when it was the last one,
+// so that an upstream call nobody waits for any more stops.
+func (t *Transport) leave(key string, c *call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c.waiters--; c.waiters == 0 {
+		delete(t.calls, key)
+		c.cancel()
+	}
+}
+
+// privatePaths are never cached: they report on the key and the account
+// it belongs to, which must reflect their current state.
+var privatePaths = []string{"/authentication", "/account"}
+
+// private reports whether a normalised request is for a private path.
+func private(request string) bool {
+	path, _, _ := strings.Cut(request, "?")
+	for _, prefix := range privatePaths {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Transport) fetch(req *http.Request, key string) (*response, error) {
+	if t.Policy == NetworkOnly {
+		return t.roundTrip(req, key, nil, nil)
+	}
+	data, meta, err := t.Store.Get(key)
+	if errors.Is(err, ErrCorrupt) {
+		// Drop the damaged entry so it is replaced by a fresh copy.
+		t.Store.Delete(key)
+	}
+	if err == nil && meta.Schema < tmdb.CompatibleModelVersion && t.Policy != CacheOnly {
+		// Entries hold the raw upstream response, which newer models
+		// decode as they please, unless they predate the oldest
+		// compatible version.
+		t.Store.Delete(key)
+		err = ErrNotFound
+	}
+	if err != nil {
+		if tombstone := t.tombstone(key); tombstone != nil {
+			return tombstone, nil
+		}
+		if t.Policy == CacheOnly {
+			return nil, &NotCachedError{Key: key}
+		}
+		return t.roundTrip(req, key, nil, nil)
+	}
+	cached := &response{
+		status: http.StatusOK,
+		header: http.Header{"Content-Type": {"application/json"}},
+		data:   data,
+		cached: true,
+	}
+	if !t.stale(meta) || t.Policy == CacheOnly {
+		return cached, nil
+	}
+	if t.Policy == StaleWhileRevalidate {
+		go t.revalidate(req, key, cached, meta)
+		return cached, nil
+	}
+	res, err := t.roundTrip(req, key, cached, meta)
+	if err != nil || unavailable(res.status) {
+		// Better stale than nothing when upstream is unreachable, failing
+		// or rate limiting. It still counts as a miss.
+		stale := *cached
+		stale.cached = false
+		return &stale, nil
+	}
+	return res, nil
+}
+
+// unavailable reports whether an upstream status means the request may
+// succeed later, rather than that the resource changed.
+func unavailable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+func (t *Transport) stale(meta *Metadata) bool {
+	if t.HonorCacheControl && !meta.Expires.IsZero() {
+		return time.Now().After(meta.Expires)
+	}
+	return t.MaxAge > 0 && time.Since(meta.ModTime) > t.MaxAge
+}
+
+func (t *Transport) notFoundTTL() time.Duration {
+	if t.NotFoundTTL == 0 {
+		return DefaultNotFoundTTL
+	}
+	return t.NotFoundTTL
+}
+
+// tombstone returns the remembered not-found response for key, if any.
+func (t *Transport) tombstone(key string) *response {
+	if t.notFoundTTL() < 0 {
+		return nil
+	}
+	data, meta, err := t.Store.Get(notFoundPrefix + key)
+	if err != nil {
+		return nil
+	}
+	if time.Since(meta.ModTime) > t.notFoundTTL() {
+		t.Store.Delete(notFoundPrefix + key)
+		return nil
+	}
+	return &response{
+		status: http.StatusNotFound,
+		header: http.Header{"Content-Type": {"application/json"}},
+		data:   data,
+		cached: true,
+	}
+}
+
+// revalidate refreshes an entry in the background, detached from the
+// context of the request that found it stale.
+func (t *Transport) revalidate(req *http.Request, key string, cached *response, meta *Metadata) {
+	t.group.Do("revalidate "+key, func() (any, error) {
+		return t.roundTrip(req.Clone(context.Background()), key, cached, meta)
+	})
+}
+
+// roundTrip sends the request upstream and stores a successful response.
+// When a stale cached copy with validators is given, the request is made
+// conditional and a 304 Not Modified refreshes the copy instead.
+func (t *Transport) roundTrip(req *http.Request, key string, cached *response, cachedMeta *Metadata) (*response, error) {
+	if cachedMeta != nil && (cachedMeta.ETag != "" || cachedMeta.LastModified != "") {
+		req = req.Clone(req.Context())
+		if cachedMeta.ETag != "" {
+			req.Header.Set("If-None-Match", cachedMeta.ETag)
+		}
+		if cachedMeta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cachedMeta.LastModified)
+		}
+	}
+	if hook, ok := req.Context().Value(upstreamHook{}).(func(context.Context) error); ok {
+		if err := hook(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	res, err := t.next().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.stats.bytesFetched.Add(int64(len(data)))
+	meta := &Metadata{Request: t.request(req), Schema: tmdb.ModelVersion}
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		meta.ETag = cachedMeta.ETag
+		meta.LastModified = cachedMeta.LastModified
+		validators(res.Header, meta)
+		t.set(key, cached.data, meta)
+		// Revalidating took a request upstream, so it counts as a miss.
+		return &response{status: cached.status, header: cached.header, data: cached.data}, nil
+	}
+	validators(res.Header, meta)
+	switch {
+	case res.StatusCode == http.StatusOK:
+		t.set(key, data, meta)
+		if t.notFoundTTL() >= 0 {
+			t.Store.Delete(notFoundPrefix + key)
+		}
+	case isNotFound(res.StatusCode, data):
+		// The resource is gone: the positive entry would otherwise be
+		// found first, and refetched, on every later request.
+		t.Store.Delete(key)
+		if t.notFoundTTL() >= 0 {
+			t.set(notFoundPrefix+key, data, meta)
+		}
+	}
+	return &response{status: res.StatusCode, header: res.Header, data: data}, nil
+}
+
+// validators copies the validators and expiry of a response into meta,
+// keeping those already there when the response has none.
+func validators(header http.Header, meta *Metadata) {
+	if etag := header.Get("ETag"); etag != "" {
+		meta.ETag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		meta.LastModified = lastModified
+	}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				meta.Expires = time.Now().Add(time.Duration(seconds) * time.Second)
+				return
+			}
+		}
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		meta.Expires = expires
+	}
+}
+
+func (t *Transport) hit(res *response) {
+	t.stats.hits.Add(1)
+	t.stats.bytesServed.Add(int64(len(res.data)))
+}
+
+// lowWater is the share of MaxSize that eviction brings the store down to,
+// so that it is not measured again on every write once full.
+const lowWater = 0.9
+
+// set stores an entry and evicts others if that takes the store over
+// MaxSize. The size of the store is tracked as an estimate between
+// evictions, which measure it exactly.
+func (t *Transport) set(key string, data []byte, meta *Metadata) {
+	if err := t.Store.Set(key, data, meta); err != nil {
+		t.storeError(key, err)
+		return
+	}
+	if t.MaxSize <= 0 {
+		return
+	}
+	size := int64(len(data))
+	if store, ok := t.Store.(interface{ diskSize(string) int64 }); ok {
+		size = store.diskSize(key)
+	}
+	t.sizeOnce.Do(func() {
+		if total, err := usage(t.Store); err == nil {
+			t.size.Store(total - size)
+		}
+	})
+	if t.size.Add(size) > t.MaxSize {
+		if _, err := t.Evict(int64(float64(t.MaxSize) * lowWater)); err != nil {
+			t.storeError(key, err)
+		}
+	}
+}
+
+func (t *Transport) storeError(key string, err error) {
+	t.stats.storeErrors.Add(1)
+	if t.OnStoreError != nil {
+		t.OnStoreError(key, err)
+	}
+}
+
+// Evict removes the least recently used entries until the store holds at
+// most maxSize bytes, and reports how many were removed.
+func (t *Transport) Evict(maxSize int64) (n int, err error) {
+	t.evicting.Lock()
+	defer t.evicting.Unlock()
+	list, err := t.Store.List("")
+	if err != nil {
+		return
+	}
+	var size int64
+	for _, meta := range list {
+		size += meta.storedSize()
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].AccessTime.Before(list[j].AccessTime)
+	})
+	for _, meta := range list {
+		if size <= maxSize {
+			break
+		}
+		if err = t.Store.Delete(meta.Key); err != nil {
+			break
+		}
+		size -= meta.storedSize()
+		n++
+	}
+	t.size.Store(size)
+	return
+}
+
+func usage(store Store) (size int64, err error) {
+	list, err := store.List("")
+	for _, meta := range list {
+		size += meta.storedSize()
+	}
+	return
+}
+
+func isNotFound(status int, data []byte) bool {
+	if status == http.StatusNotFound {
+		return true
+	}
+	var resp tmdb.TMDBResponse
+	json.Unmarshal(data, &resp)
+	return resp.StatusCode == tmdb.StatusNotFound
+}
+
+func (r *response) build(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.data)),
+		ContentLength: int64(len(r.data)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,647 @@
+package persistent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+// newTestClient returns a client of an httptest server running handler,
+// caching in memory, with the number of requests the server received.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *atomic.Int64) {
+	t.Helper()
+	hits := new(atomic.Int64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient(&Config{
+		Config: tmdb.Config{API: server.URL + "/3", APIKey: "key", KeepRaw: true},
+		Store:  NewMemoryStore(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, hits
+}
+
+func TestTransportCoalesces(t *testing.T) {
+	const n = 32
+	for _, test := range []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"found", http.StatusOK, `{"id":603,"title":"The Matrix"}`},
+		{"not found", http.StatusNotFound, `{"status_code":34,"status_message":"The resource you requested could not be found."}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			release := make(chan struct{})
+			client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				// Hold the first response until every caller is waiting.
+				<-release
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.body)
+			})
+			// The transport is used directly, as tmdb.Client coalesces
+			// requests of its own.
+			httpClient := &http.Client{Transport: client.transport}
+			url := client.transport.Base + "/movie/603?api_key=key"
+			var wg sync.WaitGroup
+			bodies := make([]string, n)
+			errs := make([]string, n)
+			for i := 0; i < n; i++ {
+				i := i
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := httpClient.Get(url)
+					if err != nil {
+						errs[i] = err.Error()
+						return
+					}
+					defer res.Body.Close()
+					data, err := io.ReadAll(res.Body)
+					if err != nil {
+						errs[i] = err.Error()
+					}
+					bodies[i] = fmt.Sprintf("%d %s", res.StatusCode, data)
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			if got := hits.Load(); got != 1 {
+				t.Errorf("upstream received %d requests, want 1", got)
+			}
+			for i := 1; i < n; i++ {
+				if bodies[i] != bodies[0] || errs[i] != errs[0] {
+					t.Fatalf("caller %d got %q, %q; caller 0 got %q, %q", i, bodies[i], errs[i], bodies[0], errs[0])
+				}
+			}
+			if want := fmt.Sprintf("%d %s", test.status, test.body); bodies[0] != want || errs[0] != "" {
+				t.Errorf("got %q, %q, want %q", bodies[0], errs[0], want)
+			}
+			stats, err := client.Stats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Hits+stats.Misses != n {
+				t.Errorf("counted %d hits and %d misses, want %d requests", stats.Hits, stats.Misses, n)
+			}
+		})
+	}
+}
+
+func TestTransportCoalescedCallerCancels(t *testing.T) {
+	release := make(chan struct{})
+	upstreamDone := make(chan error, 1)
+	client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// Only the first movie is ever released.
+		wait := release
+		if r.URL.Path != "/3/movie/603" {
+			wait = nil
+		}
+		select {
+		case <-wait:
+			fmt.Fprint(w, `{"id":603}`)
+			upstreamDone <- nil
+		case <-r.Context().Done():
+			upstreamDone <- r.Context().Err()
+		}
+	})
+	httpClient := &http.Client{Transport: client.transport}
+	get := func(ctx context.Context, id int) (string, error) {
+		url := fmt.Sprintf("%s/movie/%d?api_key=key", client.transport.Base, id)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		res, err := httpClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		data, err := io.ReadAll(res.Body)
+		return string(data), err
+	}
+
+	t.Run("one of two", func(t *testing.T) {
+		first, cancel := context.WithCancel(context.Background())
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := get(first, 603)
+			firstErr <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+		second := make(chan string, 1)
+		go func() {
+			body, err := get(context.Background(), 603)
+			if err != nil {
+				body = err.Error()
+			}
+			second <- body
+		}()
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		if err := <-firstErr; !errors.Is(err, context.Canceled) {
+			t.Errorf("cancelled caller got %v, want context.Canceled", err)
+		}
+		close(release)
+		if body := <-second; body != `{"id":603}` {
+			t.Errorf("other caller got %q", body)
+		}
+		if err := <-upstreamDone; err != nil {
+			t.Errorf("upstream request ended with %v", err)
+		}
+		if got := hits.Load(); got != 1 {
+			t.Errorf("upstream received %d requests, want 1", got)
+		}
+	})
+
+	t.Run("all", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			_, err := get(ctx, 604)
+			done <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+		select {
+		case err := <-upstreamDone:
+			if err == nil {
+				t.Error("upstream request completed, want it cancelled")
+			}
+		case <-time.After(2 * time.Second):
+			t.Error("upstream request still running after every caller left")
+		}
+	})
+}
+
+func TestTransportServesStaleOnUpstreamFailure(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var failing atomic.Bool
+			client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if failing.Load() {
+					w.WriteHeader(status)
+					fmt.Fprint(w, `{"status_code":11,"status_message":"Internal error."}`)
+					return
+				}
+				fmt.Fprint(w, `{"id":603,"title":"The Matrix"}`)
+			})
+			client.transport.MaxAge = time.Nanosecond
+			if _, err := client.GetMovieDetail(603, nil); err != nil {
+				t.Fatal(err)
+			}
+			failing.Store(true)
+			time.Sleep(time.Millisecond)
+			detail, err := client.GetMovieDetail(603, nil)
+			if err != nil {
+				t.Fatalf("got %v, want the stale entry", err)
+			}
+			if detail.Title != "The Matrix" {
+				t.Fatalf("got title %q", detail.Title)
+			}
+		})
+	}
+}
+
+func TestTransportStaleEntryBecomesNotFound(t *testing.T) {
+	var gone atomic.Bool
+	client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if gone.Load() {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"status_code":34,"status_message":"The resource you requested could not be found."}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":603,"title":"The Matrix"}`)
+	})
+	client.transport.MaxAge = time.Nanosecond
+	if _, err := client.GetMovieDetail(603, nil); err != nil {
+		t.Fatal(err)
+	}
+	gone.Store(true)
+	time.Sleep(time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetMovieDetail(603, nil); !errors.Is(err, tmdb.ErrNotFound) {
+			t.Fatalf("request %d: got %v, want ErrNotFound", i, err)
+		}
+	}
+	// The first request and the refetch that found the resource gone;
+	// the tombstone answers the others.
+	if got := hits.Load(); got != 2 {
+		t.Errorf("upstream received %d requests, want 2", got)
+	}
+	list, err := client.Store.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !strings.HasPrefix(list[0].Key, notFoundPrefix) {
+		t.Errorf("store holds %v, want the tombstone only", list)
+	}
+}
+
+func TestTransportEvictsToLowWater(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Compression = Gzip
+	body := `{"overview":"` + strings.Repeat("The Matrix ", 200) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	transport := &Transport{Store: store, Base: server.URL + "/3"}
+	httpClient := &http.Client{Transport: transport}
+	get := func(id int) {
+		res, err := httpClient.Get(fmt.Sprintf("%s/3/movie/%d", server.URL, id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	get(0)
+	list, err := store.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := list[0].DiskSize
+	if entry >= int64(len(body)) {
+		t.Fatalf("disk size %d not compressed below %d", entry, len(body))
+	}
+	// Room for ten compressed entries, far fewer uncompressed ones.
+	transport.MaxSize = 10 * entry
+	low := int64(float64(transport.MaxSize) * lowWater)
+	entries, evictions := 1, 0
+	for id := 1; id <= 20; id++ {
+		get(id)
+		list, err := store.List("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := usage(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size > transport.MaxSize {
+			t.Fatalf("store holds %d bytes, want at most %d", size, transport.MaxSize)
+		}
+		if len(list) <= entries {
+			evictions++
+			if size > low {
+				t.Errorf("store holds %d bytes after eviction, want at most %d", size, low)
+			}
+		}
+		entries = len(list)
+	}
+	if evictions == 0 {
+		t.Error("nothing was evicted")
+	}
+	if entries < 8 {
+		t.Errorf("store holds %d entries, want about 9", entries)
+	}
+}
+
+func TestTransportReportsStoreErrors(t *testing.T) {
+	if _, err := NewClient(&Config{PersistentPath: t.TempDir(), Compression: "brotli"}); err == nil {
+		t.Fatal("NewClient accepted an unknown compression")
+	}
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Set after construction, the field cannot be validated up front.
+	store.Compression = "brotli"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":603}`)
+	}))
+	t.Cleanup(server.Close)
+	var reported []string
+	client, err := NewClient(&Config{
+		Config:       tmdb.Config{API: server.URL + "/3"},
+		Store:        store,
+		OnStoreError: func(key string, err error) { reported = append(reported, key) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetMovieDetail(603, nil); err != nil {
+		t.Fatalf("got %v, want the uncached response", err)
+	}
+	stats, err := client.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 || stats.StoreErrors != 1 {
+		t.Errorf("reported %v, counted %d, want one store error", reported, stats.StoreErrors)
+	}
+}
+
+func TestTransportRevalidates(t *testing.T) {
+	const lastModified = "Wed, 31 Mar 1999 00:00:00 GMT"
+	var conditional atomic.Int64
+	client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == lastModified {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified)
+		fmt.Fprint(w, `{"id":603,"title":"The Matrix"}`)
+	})
+	client.transport.MaxAge = time.Nanosecond
+	if _, err := client.GetMovieDetail(603, nil); err != nil {
+		t.Fatal(err)
+	}
+	list, err := client.Store.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, stored := list[0].Key, list[0].ModTime
+	time.Sleep(time.Millisecond)
+	detail, err := client.GetMovieDetail(603, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.Title != "The Matrix" {
+		t.Errorf("got title %q from the revalidated entry", detail.Title)
+	}
+	if hits.Load() != 2 || conditional.Load() != 1 {
+		t.Fatalf("upstream received %d requests, %d conditional, want 2 and 1", hits.Load(), conditional.Load())
+	}
+	data, meta, err := client.Store.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"id":603,"title":"The Matrix"}` {
+		t.Errorf("entry holds %s after a 304", data)
+	}
+	if !meta.ModTime.After(stored) {
+		t.Errorf("entry stored at %v, not refreshed after %v", meta.ModTime, stored)
+	}
+	if meta.ETag != `"v1"` || meta.LastModified != lastModified {
+		t.Errorf("entry lost its validators: %q, %q", meta.ETag, meta.LastModified)
+	}
+}
+
+func TestTransportHonorsMaxAge(t *testing.T) {
+	for _, test := range []struct {
+		cacheControl string
+		want         int64
+	}{
+		{"public, max-age=3600", 1},
+		{"max-age=0", 2},
+		{"", 2},
+	} {
+		client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if test.cacheControl != "" {
+				w.Header().Set("Cache-Control", test.cacheControl)
+			}
+			fmt.Fprint(w, `{"id":603,"title":"The Matrix"}`)
+		})
+		// Stale at once, unless upstream says otherwise.
+		client.transport.MaxAge = time.Nanosecond
+		client.transport.HonorCacheControl = true
+		for i := 0; i < 2; i++ {
+			if _, err := client.GetMovieDetail(603, nil); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if got := hits.Load(); got != test.want {
+			t.Errorf("Cache-Control %q: upstream received %d requests, want %d", test.cacheControl, got, test.want)
+		}
+	}
+}
+
+func TestTransportPolicies(t *testing.T) {
+	t.Run("cache-only", func(t *testing.T) {
+		client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id":603,"title":"The Matrix"}`)
+		})
+		client.transport.Policy = CacheOnly
+		_, err := client.GetMovieDetail(603, nil)
+		var notCached *NotCachedError
+		if !errors.As(err, &notCached) {
+			t.Fatalf("got %v, want a *NotCachedError", err)
+		}
+		if hits.Load() != 0 {
+			t.Errorf("upstream received %d requests, want none", hits.Load())
+		}
+		// Entries are served once cached, even stale ones.
+		client.transport.Policy = CacheFirst
+		if _, err := client.GetMovieDetail(603, nil); err != nil {
+			t.Fatal(err)
+		}
+		client.transport.Policy = CacheOnly
+		client.transport.MaxAge = time.Nanosecond
+		time.Sleep(time.Millisecond)
+		if _, err := client.GetMovieDetail(603, nil); err != nil {
+			t.Fatalf("got %v, want the stale entry", err)
+		}
+		if hits.Load() != 1 {
+			t.Errorf("upstream received %d requests, want 1", hits.Load())
+		}
+	})
+	t.Run("network-only", func(t *testing.T) {
+		var title atomic.Value
+		title.Store("The Matrix")
+		client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"id":603,"title":%q}`, title.Load())
+		})
+		client.transport.Policy = NetworkOnly
+		for i := 0; i < 2; i++ {
+			if _, err := client.GetMovieDetail(603, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if hits.Load() != 2 {
+			t.Errorf("upstream received %d requests, want 2", hits.Load())
+		}
+		// The cache is still refreshed for the other policies.
+		title.Store("Matrix")
+		if _, err := client.GetMovieDetail(603, nil); err != nil {
+			t.Fatal(err)
+		}
+		client.transport.Policy = CacheOnly
+		detail, err := client.GetMovieDetail(603, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detail.Title != "Matrix" {
+			t.Errorf("cache holds title %q, want the latest fetch", detail.Title)
+		}
+	})
+	t.Run("stale-while-revalidate", func(t *testing.T) {
+		var title atomic.Value
+		title.Store("The Matrix")
+		refreshed := make(chan struct{}, 1)
+		client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"id":603,"title":%q}`, title.Load())
+			if title.Load() == "Matrix" {
+				refreshed <- struct{}{}
+			}
+		})
+		client.transport.Policy = StaleWhileRevalidate
+		client.transport.MaxAge = time.Nanosecond
+		if _, err := client.GetMovieDetail(603, nil); err != nil {
+			t.Fatal(err)
+		}
+		title.Store("Matrix")
+		time.Sleep(time.Millisecond)
+		detail, err := client.GetMovieDetail(603, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detail.Title != "The Matrix" {
+			t.Errorf("got title %q, want the stale one", detail.Title)
+		}
+		select {
+		case <-refreshed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("stale entry was not refreshed in the background")
+		}
+		// The refresh is stored after the response is written.
+		for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(time.Millisecond) {
+			client.transport.Policy = CacheOnly
+			detail, err = client.GetMovieDetail(603, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if detail.Title == "Matrix" || time.Now().After(deadline) {
+				break
+			}
+		}
+		if detail.Title != "Matrix" {
+			t.Errorf("cache holds title %q after the refresh", detail.Title)
+		}
+		if hits.Load() != 2 {
+			t.Errorf("upstream received %d requests, want 2", hits.Load())
+		}
+	})
+}
+
+func TestTransportModelVersion(t *testing.T) {
+	for _, test := range []struct {
+		schema int
+		want   int64
+	}{
+		{tmdb.ModelVersion, 1},
+		{tmdb.CompatibleModelVersion, 1},
+		{tmdb.CompatibleModelVersion - 1, 2},
+	} {
+		client, hits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id":603,"title":"The Matrix"}`)
+		})
+		if _, err := client.GetMovieDetail(603, nil); err != nil {
+			t.Fatal(err)
+		}
+		list, err := client.Store.List("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta := *list[0]
+		meta.Schema = test.schema
+		if err := client.Store.Set(meta.Key, []byte(`{"id":603,"title":"Matrix"}`), &meta); err != nil {
+			t.Fatal(err)
+		}
+		// An incompatible entry is still better than nothing offline.
+		client.transport.Policy = CacheOnly
+		if _, err := client.GetMovieDetail(603, nil); err != nil {
+			t.Fatalf("schema %d: got %v offline, want the entry", test.schema, err)
+		}
+		client.transport.Policy = CacheFirst
+		detail, err := client.GetMovieDetail(603, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hits.Load(); got != test.want {
+			t.Errorf("schema %d: upstream received %d requests, want %d", test.schema, got, test.want)
+		}
+		if refetched := detail.Title == "The Matrix"; refetched != (test.want == 2) {
+			t.Errorf("schema %d: got title %q", test.schema, detail.Title)
+		}
+		_, stored, err := client.Store.Get(meta.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.want == 2 && stored.Schema != tmdb.ModelVersion {
+			t.Errorf("schema %d: refetched entry has schema %d, want %d", test.schema, stored.Schema, tmdb.ModelVersion)
+		}
+	}
+}
+
+func TestTransportReplacesCorruptEntries(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cut  func(raw []byte) []byte
+	}{
+		{"truncated payload", func(raw []byte) []byte { return raw[:len(raw)-5] }},
+		{"truncated header", func(raw []byte) []byte { return raw[:len(fileMagic)+10] }},
+		{"garbage", func(raw []byte) []byte { return []byte("\x00\x01garbage") }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var hits atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				fmt.Fprint(w, `{"id":603,"title":"The Matrix"}`)
+			}))
+			t.Cleanup(server.Close)
+			client, err := NewClient(&Config{
+				Config:         tmdb.Config{API: server.URL + "/3", APIKey: "key"},
+				PersistentPath: t.TempDir(),
+				Compression:    Gzip,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.GetMovieDetail(603, nil); err != nil {
+				t.Fatal(err)
+			}
+			store := client.Store.(*FileStore)
+			list, err := store.List("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := list[0].Key
+			raw, err := os.ReadFile(store.flat(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(store.flat(key), test.cut(raw), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			detail, err := client.GetMovieDetail(603, nil)
+			if err != nil {
+				t.Fatalf("got %v, want the refetched entry", err)
+			}
+			if detail.Title != "The Matrix" || hits.Load() != 2 {
+				t.Errorf("got title %q after %d upstream requests, want a refetch", detail.Title, hits.Load())
+			}
+			if data, _, err := store.Get(key); err != nil || !bytes.Equal(data, []byte(`{"id":603,"title":"The Matrix"}`)) {
+				t.Errorf("store holds %q, %v after the refetch", data, err)
+			}
+		})
+	}
+}
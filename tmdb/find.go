@@ -0,0 +1,61 @@
+package tmdb
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// External sources accepted by Find.
+const (
+	SourceIMDb      = "imdb_id"
+	SourceTVDB      = "tvdb_id"
+	SourceWikidata  = "wikidata_id"
+	SourceFacebook  = "facebook_id"
+	SourceInstagram = "instagram_id"
+	SourceTwitter   = "twitter_id"
+	SourceTikTok    = "tiktok_id"
+	SourceYouTube   = "youtube_id"
+)
+
+type FindRequest struct {
+	// ExternalSource is one of the Source constants, SourceIMDb when empty.
+	ExternalSource string `json:"external_source"`
+	Language       string `json:"language"`
+}
+
+type FindResponse struct {
+	MovieResults     []MovieObject     `json:"movie_results"`
+	PersonResults    []PersonObject    `json:"person_results"`
+	TVResults        []TVObject        `json:"tv_results"`
+	TVEpisodeResults []TVEpisodeDetail `json:"tv_episode_results"`
+	TVSeasonResults  []struct {
+		ID           int     `json:"id"`
+		Name         string  `json:"name"`
+		AirDate      string  `json:"air_date"`
+		PosterPath   string  `json:"poster_path"`
+		SeasonNumber int     `json:"season_number"`
+		ShowID       int     `json:"show_id"`
+		VoteAverage  float32 `json:"vote_average"`
+	} `json:"tv_season_results"`
+	RawResponse
+}
+
+// Find movies, TV shows and people by an external ID such as an IMDb ID.
+// https://developer.themoviedb.org/reference/find-by-id
+func (client *Client) Find(externalID string, opts *FindRequest) (res *FindResponse, err error) {
+	if opts == nil {
+		opts = &FindRequest{}
+	}
+	if opts.ExternalSource == "" {
+		opts.ExternalSource = SourceIMDb
+	}
+	data, err := client.get(fmt.Sprintf("/find/%s", url.PathEscape(externalID)), map[string]string{
+		"external_source": opts.ExternalSource,
+		"language":        opts.Language,
+	})
+	if err != nil {
+		return
+	}
+	res, err = decode[FindResponse](client, data)
+	return
+}
@@ -0,0 +1,45 @@
+package tmdb
+
+type ImagesRequest struct {
+	Language string `json:"language"`
+	// IncludeImageLanguage is a comma separated list of extra languages to
+	// include, such as "en,null" where null selects images without text.
+	IncludeImageLanguage string `json:"include_image_language"`
+}
+
+type Image struct {
+	AspectRatio float32 `json:"aspect_ratio"`
+	Height      int     `json:"height"`
+	Width       int     `json:"width"`
+	ISO639_1    string  `json:"iso_639_1"`
+	FilePath    string  `json:"file_path"`
+	VoteAverage float32 `json:"vote_average"`
+	VoteCount   int     `json:"vote_count"`
+}
+
+type Images struct {
+	ID        int     `json:"id"`
+	Backdrops []Image `json:"backdrops"`
+	Logos     []Image `json:"logos"`
+	Posters   []Image `json:"posters"`
+	// Stills are filled for episodes only.
+	Stills []Image `json:"stills"`
+	RawResponse
+}
+
+// getImages queries one of the images endpoints, which share their
+// parameters and response.
+func (client *Client) getImages(path string, opts *ImagesRequest) (images *Images, err error) {
+	if opts == nil {
+		opts = &ImagesRequest{}
+	}
+	data, err := client.get(path, map[string]string{
+		"language":               opts.Language,
+		"include_image_language": opts.IncludeImageLanguage,
+	})
+	if err != nil {
+		return
+	}
+	images, err = decode[Images](client, data)
+	return
+}
@@ -1,7 +1,6 @@
 package tmdb
 
 import (
-	"encoding/json"
 	"fmt"
 	"strconv"
 )
@@ -28,6 +27,7 @@ type SearchMovieResponse struct {
 	TotalPages   int           `json:"total_pages"`
 	TotalResults int           `json:"total_results"`
 	Results      []MovieObject `json:"results"`
+	RawResponse
 }
 
 type SearchMovieRequest struct {
@@ -82,6 +82,7 @@ type MovieDetail struct {
 		Name        string `json:"name"`
 		EnglishName string `json:"english_name"`
 	} `json:"spoken_languages"`
+	RawResponse
 }
 
 type MovieDetailRequest struct {
@@ -124,6 +125,30 @@ type MovieCredits struct {
 	ID   int          `json:"id"`
 	Cast []CastMember `json:"cast"`
 	Crew []CrewMember `json:"crew"`
+	RawResponse
+}
+
+type MovieVideosRequest struct {
+	Language string `json:"language"`
+}
+
+type Video struct {
+	ID          string `json:"id"`
+	ISO639_1    string `json:"iso_639_1"`
+	ISO3166_1   string `json:"iso_3166_1"`
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	Site        string `json:"site"`
+	Size        int    `json:"size"`
+	Type        string `json:"type"`
+	Official    bool   `json:"official"`
+	PublishedAt string `json:"published_at"`
+}
+
+type MovieVideos struct {
+	ID      int     `json:"id"`
+	Results []Video `json:"results"`
+	RawResponse
 }
 
 // Search for movies by their original, translated and alternative titles.
@@ -147,7 +172,7 @@ func (client *Client) SearchMovie(query string, opts *SearchMovieRequest) (res *
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(data, &res)
+	res, err = decode[SearchMovieResponse](client, data)
 	return
 }
 
@@ -158,12 +183,13 @@ func (client *Client) GetMovieDetail(id int, opts *MovieDetailRequest) (detail *
 		opts = &MovieDetailRequest{}
 	}
 	data, err := client.get(fmt.Sprintf("/movie/%d", id), map[string]string{
-		"language": opts.Language,
+		"language":           opts.Language,
+		"append_to_response": opts.AppendToResponse,
 	})
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(data, &detail)
+	detail, err = decode[MovieDetail](client, data)
 	return
 }
 
@@ -179,6 +205,28 @@ func (client *Client) GetMovieCredits(id int, opts *MovieCreditsRequest) (credit
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(data, &credits)
+	credits, err = decode[MovieCredits](client, data)
+	return
+}
+
+// Get the images that belong to a movie.
+// https://developer.themoviedb.org/reference/movie-images
+func (client *Client) GetMovieImages(id int, opts *ImagesRequest) (images *Images, err error) {
+	return client.getImages(fmt.Sprintf("/movie/%d/images", id), opts)
+}
+
+// Get the trailers, teasers, clips and other videos of a movie.
+// https://developer.themoviedb.org/reference/movie-videos
+func (client *Client) GetMovieVideos(id int, opts *MovieVideosRequest) (videos *MovieVideos, err error) {
+	if opts == nil {
+		opts = &MovieVideosRequest{}
+	}
+	data, err := client.get(fmt.Sprintf("/movie/%d/videos", id), map[string]string{
+		"language": opts.Language,
+	})
+	if err != nil {
+		return
+	}
+	videos, err = decode[MovieVideos](client, data)
 	return
 }
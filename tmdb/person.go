@@ -0,0 +1,59 @@
+package tmdb
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type PersonObject struct {
+	Adult              bool    `json:"adult"`
+	Gender             int     `json:"gender"`
+	ID                 int     `json:"id"`
+	KnownForDepartment string  `json:"known_for_department"`
+	Name               string  `json:"name"`
+	OriginalName       string  `json:"original_name"`
+	Popularity         float32 `json:"popularity"`
+	ProfilePath        string  `json:"profile_path"`
+	KnownFor           []struct {
+		ID        int    `json:"id"`
+		MediaType string `json:"media_type"`
+		Title     string `json:"title"`
+		Name      string `json:"name"`
+	} `json:"known_for"`
+}
+
+type SearchPersonResponse struct {
+	Page         int            `json:"page"`
+	TotalPages   int            `json:"total_pages"`
+	TotalResults int            `json:"total_results"`
+	Results      []PersonObject `json:"results"`
+	RawResponse
+}
+
+type SearchPersonRequest struct {
+	IncludeAdult bool   `json:"include_adult"`
+	Language     string `json:"language"`
+	Page         int32  `json:"page"`
+}
+
+// Search for people by their name and also known as names.
+// https://developer.themoviedb.org/reference/search-person
+func (client *Client) SearchPerson(query string, opts *SearchPersonRequest) (res *SearchPersonResponse, err error) {
+	if opts == nil {
+		opts = &SearchPersonRequest{}
+	}
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	data, err := client.get("/search/person", map[string]string{
+		"query":         query,
+		"page":          fmt.Sprint(opts.Page),
+		"language":      opts.Language,
+		"include_adult": strconv.FormatBool(opts.IncludeAdult),
+	})
+	if err != nil {
+		return
+	}
+	res, err = decode[SearchPersonResponse](client, data)
+	return
+}
@@ -0,0 +1,81 @@
+package tmdb
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// RawResponse is embedded in every response type. Its Raw field holds
+// the response as received when Config.KeepRaw is set.
+type RawResponse struct {
+	Raw json.RawMessage `json:"-"`
+}
+
+func (r *RawResponse) setRaw(data []byte) {
+	r.Raw = data
+}
+
+// rawSetter is implemented by the types embedding RawResponse.
+type rawSetter interface {
+	setRaw(data []byte)
+}
+
+// decode unmarshals a response, keeping data in its Raw field when
+// Config.KeepRaw is set.
+func decode[T any](client *Client, data []byte) (v *T, err error) {
+	if err = json.Unmarshal(data, &v); err != nil || v == nil {
+		return
+	}
+	if r, ok := any(v).(rawSetter); ok && client.config.KeepRaw {
+		r.setRaw(data)
+	}
+	return
+}
+
+// Extra returns the top-level fields of raw that the struct v, or a
+// pointer to it, does not declare. It makes fields added by TMDB usable
+// before the types here catch up, given a response's Raw field:
+//
+//	detail, _ := client.GetMovieDetail(603, nil)
+//	extra, _ := tmdb.Extra(detail.Raw, detail)
+func Extra(raw json.RawMessage, v any) (extra map[string]json.RawMessage, err error) {
+	if err = json.Unmarshal(raw, &extra); err != nil {
+		return
+	}
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Struct {
+		for name := range jsonFields(t) {
+			delete(extra, name)
+		}
+	}
+	return
+}
+
+// jsonFields collects the JSON names of the fields of t, including those
+// promoted from embedded structs.
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for name := range jsonFields(field.Type) {
+				fields[name] = true
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
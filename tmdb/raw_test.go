@@ -0,0 +1,47 @@
+package tmdb_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/song940/tmdb-go/tmdb"
+	"github.com/song940/tmdb-go/tmdbtest"
+)
+
+func TestKeepRawAndExtra(t *testing.T) {
+	const body = `{"id":603,"title":"The Matrix","runtime":136,"watch_time":{"minutes":136},"trivia":["bullet time"]}`
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	server.Handle("/movie/603", &tmdbtest.Response{Status: http.StatusOK, Body: body})
+	for _, keepRaw := range []bool{false, true} {
+		config := server.Config()
+		config.KeepRaw = keepRaw
+		client, err := tmdb.NewClient(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		detail, err := client.GetMovieDetail(603, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detail.Title != "The Matrix" || detail.Runtime != 136 {
+			t.Fatalf("decoded %q, %d", detail.Title, detail.Runtime)
+		}
+		if !keepRaw {
+			if detail.Raw != nil {
+				t.Errorf("Raw kept without KeepRaw: %s", detail.Raw)
+			}
+			continue
+		}
+		if string(detail.Raw) != body {
+			t.Fatalf("Raw is %s, want %s", detail.Raw, body)
+		}
+		extra, err := tmdb.Extra(detail.Raw, detail)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(extra) != 2 || string(extra["watch_time"]) != `{"minutes":136}` || string(extra["trivia"]) != `["bullet time"]` {
+			t.Errorf("Extra returned %v, want watch_time and trivia", extra)
+		}
+	}
+}
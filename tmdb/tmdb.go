@@ -1,11 +1,15 @@
 package tmdb
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+
+	"golang.org/x/sync/singleflight"
 )
 
 type TMDBResponse struct {
@@ -14,21 +18,66 @@ type TMDBResponse struct {
 	Success       bool   `json:"success"`
 }
 
+// ModelVersion identifies the shape of the response types and of the
+// requests that produce them. It is bumped whenever either changes, so that
+// caches can tell which version of the library wrote an entry.
+const ModelVersion = 2
+
+// CompatibleModelVersion is the oldest ModelVersion whose raw responses
+// still decode correctly into the current types. Cached responses from
+// older versions should be fetched again.
+const CompatibleModelVersion = 1
+
+// StatusNotFound is the TMDB status code for "The resource you requested
+// could not be found."
+const StatusNotFound = 34
+
+// ErrNotFound matches, with errors.Is, every *Error reporting a missing
+// resource.
+var ErrNotFound = errors.New("tmdb: resource not found")
+
+// Error is returned when TMDB answers with a status code.
+type Error struct {
+	TMDBResponse
+	// HTTPStatus is the status code of the HTTP response.
+	HTTPStatus int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code: %d)", e.StatusMessage, e.StatusCode)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrNotFound && (e.HTTPStatus == http.StatusNotFound || e.StatusCode == StatusNotFound)
+}
+
 type Config struct {
-	API         string `yaml:"api"`
-	APIKey      string `yaml:"api_key"`
-	AccessToken string `yaml:"access_token"`
-	ImageURL    string `yaml:"image_url"`
+	API         string `yaml:"api" toml:"api"`
+	APIKey      string `yaml:"api_key" toml:"api_key"`
+	AccessToken string `yaml:"access_token" toml:"access_token"`
+	ImageURL    string `yaml:"image_url" toml:"image_url"`
+	// KeepRaw keeps the JSON of every response in its Raw field, see
+	// RawResponse, so that fields the types do not declare remain
+	// available, see Extra.
+	KeepRaw bool `yaml:"keep_raw" toml:"keep_raw"`
+
+	// HTTPClient is used to send requests, http.DefaultClient when nil.
+	HTTPClient *http.Client `yaml:"-" toml:"-"`
 }
 
 type Client struct {
 	config *Config
 	http   *http.Client
+	// flight coalesces concurrent identical GET requests into one call.
+	flight singleflight.Group
 }
 
 func NewClient(config *Config) (client *Client, err error) {
 	client = &Client{config: config}
 	client.http = http.DefaultClient
+	if client.config.HTTPClient != nil {
+		client.http = client.config.HTTPClient
+	}
 	if client.config.API == "" {
 		client.config.API = "https://api.themoviedb.org/3"
 	}
@@ -57,8 +106,11 @@ func (client *Client) request(method string, path string, body io.Reader) (data
 	}
 	var resp TMDBResponse
 	json.Unmarshal(data, &resp)
-	if resp.StatusCode != 0 {
-		err = fmt.Errorf("%s (code: %d)", resp.StatusMessage, resp.StatusCode)
+	if resp.StatusCode != 0 || res.StatusCode >= http.StatusBadRequest {
+		if resp.StatusMessage == "" {
+			resp.StatusMessage = http.StatusText(res.StatusCode)
+		}
+		err = &Error{TMDBResponse: resp, HTTPStatus: res.StatusCode}
 	}
 	return
 }
@@ -73,7 +125,16 @@ func (client *Client) get(path string, query map[string]string) (data []byte, er
 			qs.Add(k, v)
 		}
 	}
-	return client.request(http.MethodGet, path+"?"+qs.Encode(), nil)
+	target := path + "?" + qs.Encode()
+	v, err, shared := client.flight.Do(target, func() (any, error) {
+		return client.request(http.MethodGet, target, nil)
+	})
+	data, _ = v.([]byte)
+	if shared {
+		// Each caller may keep the bytes as Raw.
+		data = bytes.Clone(data)
+	}
+	return
 }
 
 func (client *Client) Authentication() (resp *TMDBResponse, err error) {
@@ -0,0 +1,68 @@
+package tmdb_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/song940/tmdb-go/tmdb"
+	"github.com/song940/tmdb-go/tmdbtest"
+)
+
+// gate holds requests until it is opened.
+type gate struct {
+	open chan struct{}
+	next http.RoundTripper
+}
+
+func (g *gate) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-g.open
+	return g.next.RoundTrip(req)
+}
+
+func TestClientCoalesces(t *testing.T) {
+	const n = 16
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	g := &gate{open: make(chan struct{}), next: server.Server.Client().Transport}
+	config := server.Config()
+	config.KeepRaw = true
+	config.HTTPClient = &http.Client{Transport: g}
+	client, err := tmdb.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := make([]*tmdb.MovieDetail, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			details[i], errs[i] = client.GetMovieDetail(tmdbtest.MovieID, nil)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(g.open)
+	wg.Wait()
+	if got := len(server.Requests()); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: %v", i, errs[i])
+		}
+		if details[i].Title != "The Matrix" {
+			t.Fatalf("caller %d got title %q", i, details[i].Title)
+		}
+	}
+	// Raw must not be shared, so that a caller changing it affects no other.
+	details[0].Raw[0] = 'x'
+	for i := 1; i < n; i++ {
+		if details[i].Raw[0] != '{' {
+			t.Fatalf("caller %d sees the Raw of caller 0", i)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package tmdb
 
 import (
-	"encoding/json"
 	"fmt"
 	"strconv"
 )
@@ -28,6 +27,7 @@ type SearchTVResponse struct {
 	TotalPages   int        `json:"total_pages"`
 	TotalResults int        `json:"total_results"`
 	Results      []TVObject `json:"results"`
+	RawResponse
 }
 
 type SearchTVRequest struct {
@@ -112,6 +112,7 @@ type TVDetail struct {
 	Status  string `json:"status"`
 	Tagline string `json:"tagline"`
 	Type    string `json:"type"`
+	RawResponse
 }
 
 type TVDetailRequest struct {
@@ -150,9 +151,26 @@ type TVSeasonDetail struct {
 		Crew           []CrewMember `json:"crew"`
 		GuestStars     []GuestStar  `json:"guest_stars"`
 	}
+	RawResponse
 }
 
-type TVEpisodeDetail struct{}
+type TVEpisodeDetail struct {
+	ID             int          `json:"id"`
+	Name           string       `json:"name"`
+	Overview       string       `json:"overview"`
+	AirDate        string       `json:"air_date"`
+	Episode        int          `json:"episode_number"`
+	Season         int          `json:"season_number"`
+	ShowID         int          `json:"show_id"`
+	ProductionCode string       `json:"production_code"`
+	Runtime        int          `json:"runtime"`
+	StillPath      string       `json:"still_path"`
+	VoteAverage    float32      `json:"vote_average"`
+	VoteCount      int          `json:"vote_count"`
+	Crew           []CrewMember `json:"crew"`
+	GuestStars     []GuestStar  `json:"guest_stars"`
+	RawResponse
+}
 
 // Search for TV shows by their original, translated and also known as names.
 // https://developer.themoviedb.org/reference/search-tv
@@ -174,7 +192,7 @@ func (client *Client) SearchTV(query string, opts *SearchTVRequest) (res *Search
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(data, &res)
+	res, err = decode[SearchTVResponse](client, data)
 	return
 }
 
@@ -190,7 +208,7 @@ func (client *Client) GetTVDetail(id int, opts *TVDetailRequest) (detail *TVDeta
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(data, &detail)
+	detail, err = decode[TVDetail](client, data)
 	return
 }
 
@@ -206,7 +224,7 @@ func (client *Client) GetTVCredits(id int, opts *TVCreditsRequest) (credits *Mov
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(data, &credits)
+	credits, err = decode[MovieCredits](client, data)
 	return
 }
 
@@ -222,11 +240,12 @@ func (client *Client) GetTVSeason(id int, season int, opts *TVDetailRequest) (de
 	if err != nil {
 		return
 	}
-	detail = &TVSeasonDetail{}
-	err = json.Unmarshal(data, &detail)
+	detail, err = decode[TVSeasonDetail](client, data)
 	return
 }
 
+// Query the details of a TV episode.
+// https://developer.themoviedb.org/reference/tv-episode-details
 func (client *Client) GetTVEpisode(seriesId int, seasonNumber int, episodeNumber int, opts *TVDetailRequest) (episode *TVEpisodeDetail, err error) {
 	if opts == nil {
 		opts = &TVDetailRequest{}
@@ -237,7 +256,24 @@ func (client *Client) GetTVEpisode(seriesId int, seasonNumber int, episodeNumber
 	if err != nil {
 		return
 	}
-	episode = &TVEpisodeDetail{}
-	err = json.Unmarshal(data, &episode)
+	episode, err = decode[TVEpisodeDetail](client, data)
 	return
 }
+
+// Get the images that belong to a TV series.
+// https://developer.themoviedb.org/reference/tv-series-images
+func (client *Client) GetTVImages(id int, opts *ImagesRequest) (images *Images, err error) {
+	return client.getImages(fmt.Sprintf("/tv/%d/images", id), opts)
+}
+
+// Get the images that belong to a TV season.
+// https://developer.themoviedb.org/reference/tv-season-images
+func (client *Client) GetTVSeasonImages(id int, season int, opts *ImagesRequest) (images *Images, err error) {
+	return client.getImages(fmt.Sprintf("/tv/%d/season/%d/images", id, season), opts)
+}
+
+// Get the stills that belong to a TV episode.
+// https://developer.themoviedb.org/reference/tv-episode-images
+func (client *Client) GetTVEpisodeImages(id int, season int, episode int, opts *ImagesRequest) (images *Images, err error) {
+	return client.getImages(fmt.Sprintf("/tv/%d/season/%d/episode/%d/images", id, season, episode), opts)
+}
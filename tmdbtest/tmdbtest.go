@@ -0,0 +1,208 @@
+// Package tmdbtest provides a fake TMDB API for the tests of code built on
+// tmdb.Client. A Server answers the search, movie, TV, season and episode
+// endpoints, with their credits, videos and images, from fixtures, serves
+// the responses and errors registered by the test, and records the
+// requests it receives:
+//
+//	server := tmdbtest.NewServer()
+//	defer server.Close()
+//	server.Error("/movie/603", http.StatusTooManyRequests)
+//	client := server.Client()
+package tmdbtest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/song940/tmdb-go/tmdb"
+)
+
+// Credentials accepted by a Server. Requests with neither are refused with
+// 401, as by TMDB.
+const (
+	APIKey      = "tmdbtest-key"
+	AccessToken = "tmdbtest-token"
+)
+
+// Any matches every path in Handle and Error. Responses registered for a
+// path take precedence.
+const Any = "*"
+
+// fixtures holds one response per path, such as fixtures/movie/603.json
+// for /movie/603. The search fixtures are served whatever the query.
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// Fixture IDs.
+const (
+	MovieID       = 603  // The Matrix
+	TVID          = 1399 // Game of Thrones
+	SeasonNumber  = 1
+	EpisodeNumber = 1
+)
+
+// statusErrors are the bodies TMDB sends with the statuses of Error.
+var statusErrors = map[int]tmdb.TMDBResponse{
+	http.StatusUnauthorized:    {StatusCode: 7, StatusMessage: "Invalid API key: You must be granted a valid key."},
+	http.StatusNotFound:        {StatusCode: tmdb.StatusNotFound, StatusMessage: "The resource you requested could not be found."},
+	http.StatusTooManyRequests: {StatusCode: 25, StatusMessage: "Your request count (41) is over the allowed limit of (40)."},
+}
+
+// Request is a request received by a Server.
+type Request struct {
+	Method string
+	// Path is relative to the API root, such as /movie/603.
+	Path   string
+	Query  url.Values
+	Header http.Header
+}
+
+// Response is a response registered with Handle.
+type Response struct {
+	Status int
+	Header http.Header
+	// Body is sent as is when a string or a []byte, and as JSON otherwise.
+	Body any
+}
+
+// Server is a running fake TMDB API. Its methods may be called while
+// requests are served.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]*Response
+	requests  []Request
+}
+
+// NewServer starts a Server, which the caller should Close.
+func NewServer() *Server {
+	s := &Server{responses: make(map[string]*Response)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// API returns the API root of the server, for tmdb.Config.API.
+func (s *Server) API() string {
+	return s.URL + "/3"
+}
+
+// Config returns a configuration for a client of the server.
+func (s *Server) Config() *tmdb.Config {
+	return &tmdb.Config{
+		API:        s.API(),
+		APIKey:     APIKey,
+		ImageURL:   s.URL + "/t/p/",
+		HTTPClient: s.Server.Client(),
+	}
+}
+
+// Client returns a client of the server.
+func (s *Server) Client() *tmdb.Client {
+	client, _ := tmdb.NewClient(s.Config())
+	return client
+}
+
+// Handle registers the response to requests for path, such as /movie/603
+// or Any, replacing a fixture or an earlier response. It is served
+// whatever the credentials of the request.
+func (s *Server) Handle(path string, res *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[path] = res
+}
+
+// HandleJSON registers body, encoded as JSON, as the response to requests
+// for path.
+func (s *Server) HandleJSON(path string, body any) {
+	s.Handle(path, &Response{Status: http.StatusOK, Body: body})
+}
+
+// Error makes requests for path fail with status, with the body TMDB sends
+// for 401, 404 and 429. A 429 carries a Retry-After header.
+func (s *Server) Error(path string, status int) {
+	res := &Response{Status: status, Header: http.Header{}}
+	body, ok := statusErrors[status]
+	if !ok {
+		body = tmdb.TMDBResponse{StatusMessage: http.StatusText(status)}
+	}
+	res.Body = body
+	if status == http.StatusTooManyRequests {
+		res.Header.Set("Retry-After", "10")
+	}
+	s.Handle(path, res)
+}
+
+// Requests returns the requests received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset forgets the registered responses and the received requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = make(map[string]*Response)
+	s.requests = nil
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/3")
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{r.Method, path, r.URL.Query(), r.Header.Clone()})
+	res, ok := s.responses[path]
+	if !ok {
+		res, ok = s.responses[Any]
+	}
+	s.mu.Unlock()
+	switch {
+	case ok:
+	case r.URL.Query().Get("api_key") != APIKey && r.Header.Get("Authorization") != "Bearer "+AccessToken:
+		res = &Response{Status: http.StatusUnauthorized, Body: statusErrors[http.StatusUnauthorized]}
+	default:
+		res = fixture(path)
+	}
+	for name, values := range res.Header {
+		w.Header()[name] = values
+	}
+	var data []byte
+	switch body := res.Body.(type) {
+	case []byte:
+		data = body
+	case string:
+		data = []byte(body)
+	default:
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			http.Error(w, fmt.Sprintf("tmdbtest: %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	}
+	status := res.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// fixture returns the fixture of path, or a not-found error.
+func fixture(path string) *Response {
+	data, err := fixtures.ReadFile("fixtures" + path + ".json")
+	if err != nil || strings.Contains(path, "..") {
+		return &Response{Status: http.StatusNotFound, Body: statusErrors[http.StatusNotFound]}
+	}
+	return &Response{Status: http.StatusOK, Body: data}
+}
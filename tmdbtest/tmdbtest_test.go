@@ -0,0 +1,183 @@
+package tmdbtest_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/song940/tmdb-go/tmdb"
+	"github.com/song940/tmdb-go/tmdbtest"
+)
+
+func TestFixtures(t *testing.T) {
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	movie, err := client.GetMovieDetail(tmdbtest.MovieID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Title != "The Matrix" {
+		t.Errorf("got movie %q", movie.Title)
+	}
+	episode, err := client.GetTVEpisode(tmdbtest.TVID, tmdbtest.SeasonNumber, tmdbtest.EpisodeNumber, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if episode.Name != "Winter Is Coming" {
+		t.Errorf("got episode %q", episode.Name)
+	}
+	if _, err := client.GetMovieDetail(1, nil); !errors.Is(err, tmdb.ErrNotFound) {
+		t.Errorf("got %v for a movie without fixture, want ErrNotFound", err)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	for _, test := range []struct {
+		name      string
+		config    tmdb.Config
+		wantError bool
+	}{
+		{"key", tmdb.Config{APIKey: tmdbtest.APIKey}, false},
+		{"token", tmdb.Config{AccessToken: tmdbtest.AccessToken}, false},
+		{"wrong key", tmdb.Config{APIKey: "wrong"}, true},
+		{"none", tmdb.Config{}, true},
+	} {
+		config := test.config
+		config.API = server.API()
+		client, err := tmdb.NewClient(&config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.GetMovieDetail(tmdbtest.MovieID, nil)
+		var tmdbErr *tmdb.Error
+		switch {
+		case !test.wantError && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.wantError && (!errors.As(err, &tmdbErr) || tmdbErr.HTTPStatus != http.StatusUnauthorized || tmdbErr.StatusCode != 7):
+			t.Errorf("%s: got %v, want a 401 with status code 7", test.name, err)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	server.HandleJSON("/movie/603", map[string]any{"id": 603, "title": "Matrix"})
+	movie, err := client.GetMovieDetail(603, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Title != "Matrix" {
+		t.Errorf("got title %q, want the registered one", movie.Title)
+	}
+
+	// Responses are served whatever the credentials.
+	server.Handle("/movie/605", &tmdbtest.Response{
+		Header: http.Header{"X-Test": {"yes"}},
+		Body:   `{"id":605,"title":"The Matrix Revisited"}`,
+	})
+	res, err := http.Get(server.API() + "/movie/605")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("X-Test") != "yes" || res.Header.Get("Content-Type") == "" {
+		t.Errorf("got status %d and header %v", res.StatusCode, res.Header)
+	}
+
+	// A path takes precedence over Any, which takes precedence over the
+	// fixtures.
+	server.HandleJSON(tmdbtest.Any, map[string]any{"id": 1, "title": "Anything"})
+	if movie, err = client.GetMovieDetail(603, nil); err != nil || movie.Title != "Matrix" {
+		t.Errorf("got %v, %v, want the response registered for the path", movie, err)
+	}
+	if show, err := client.GetTVDetail(tmdbtest.TVID, nil); err != nil || show.Name != "" {
+		t.Errorf("got %v, %v, want the response registered for Any", show, err)
+	}
+
+	server.Reset()
+	if movie, err = client.GetMovieDetail(603, nil); err != nil || movie.Title != "The Matrix" {
+		t.Errorf("got %v, %v after Reset, want the fixture", movie, err)
+	}
+}
+
+func TestError(t *testing.T) {
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	for _, test := range []struct {
+		status, code int
+		notFound     bool
+	}{
+		{http.StatusUnauthorized, 7, false},
+		{http.StatusNotFound, tmdb.StatusNotFound, true},
+		{http.StatusTooManyRequests, 25, false},
+		{http.StatusInternalServerError, 0, false},
+	} {
+		server.Error("/movie/603", test.status)
+		_, err := client.GetMovieDetail(603, nil)
+		var tmdbErr *tmdb.Error
+		if !errors.As(err, &tmdbErr) {
+			t.Fatalf("%d: got %v, want a *tmdb.Error", test.status, err)
+		}
+		if tmdbErr.HTTPStatus != test.status || tmdbErr.StatusCode != test.code || tmdbErr.StatusMessage == "" {
+			t.Errorf("%d: got %+v, want status code %d", test.status, tmdbErr, test.code)
+		}
+		if errors.Is(err, tmdb.ErrNotFound) != test.notFound {
+			t.Errorf("%d: errors.Is(err, ErrNotFound) = %v", test.status, !test.notFound)
+		}
+	}
+
+	server.Error("/movie/603", http.StatusTooManyRequests)
+	res, err := http.Get(server.API() + "/movie/603")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Header.Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+
+	// TMDB may report a missing resource in the body of a 200.
+	server.HandleJSON("/movie/603", tmdb.TMDBResponse{StatusCode: tmdb.StatusNotFound, StatusMessage: "The resource you requested could not be found."})
+	if _, err := client.GetMovieDetail(603, nil); !errors.Is(err, tmdb.ErrNotFound) {
+		t.Errorf("got %v for status code 34, want ErrNotFound", err)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	server := tmdbtest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	if _, err := client.GetMovieDetail(tmdbtest.MovieID, &tmdb.MovieDetailRequest{Language: "fr"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTVDetail(tmdbtest.TVID, nil); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("recorded %d requests, want 2", len(requests))
+	}
+	first := requests[0]
+	if first.Method != http.MethodGet || first.Path != "/movie/603" || first.Query.Get("language") != "fr" || first.Query.Get("api_key") != tmdbtest.APIKey {
+		t.Errorf("recorded %+v", first)
+	}
+	if requests[1].Path != "/tv/1399" {
+		t.Errorf("recorded %s second, want /tv/1399", requests[1].Path)
+	}
+	// The copy returned is the caller's.
+	requests[0].Path = "changed"
+	if server.Requests()[0].Path != "/movie/603" {
+		t.Error("Requests returned the recorded slice")
+	}
+	server.Reset()
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("recorded %d requests after Reset", n)
+	}
+}